@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// AddressTx is an entry of the address transaction history index, it records
+// a transaction which spent from or paid to an address.
+type AddressTx struct {
+	Height uint32
+	TxId   Uint256
+}
+
+// key: IX_Address_Tx || program hash || height(big endian) || tx hash
+// the height is written in big endian so that the entries of an address are
+// iterated in height order.
+func getAddressTxKey(programHash Uint168, height uint32, txId Uint256) []byte {
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(IX_Address_Tx))
+	key.Write(programHash.Bytes())
+	var h [4]byte
+	binary.BigEndian.PutUint32(h[:], height)
+	key.Write(h[:])
+	key.Write(txId.Bytes())
+	return key.Bytes()
+}
+
+func parseAddressTxKey(key []byte) (*AddressTx, error) {
+	// prefix(1) || program hash(21) || height(4) || tx hash(32)
+	offset := 1 + len(Uint168{})
+	if len(key) != offset+4+UINT256SIZE {
+		return nil, errors.New("invalid address transaction key length")
+	}
+	height := binary.BigEndian.Uint32(key[offset : offset+4])
+	txId, err := Uint256FromBytes(key[offset+4:])
+	if err != nil {
+		return nil, err
+	}
+	return &AddressTx{Height: height, TxId: *txId}, nil
+}
+
+// getTxAddresses returns the program hashes the transaction paid to or spent
+// from, spentOutputs are the outputs spent by the block of the transaction.
+func getTxAddresses(txn *Transaction, spentOutputs map[OutPoint]*SpentOutput) ([]Uint168, error) {
+	addresses := make(map[Uint168]struct{})
+	for _, output := range txn.Outputs {
+		addresses[output.ProgramHash] = struct{}{}
+	}
+
+	if !txn.IsCoinBaseTx() {
+		for _, input := range txn.Inputs {
+			spent, ok := spentOutputs[input.Previous]
+			if !ok {
+				return nil, errors.New("[getTxAddresses] refer output not found")
+			}
+			addresses[spent.Output.ProgramHash] = struct{}{}
+		}
+	}
+
+	programHashes := make([]Uint168, 0, len(addresses))
+	for programHash := range addresses {
+		programHashes = append(programHashes, programHash)
+	}
+	return programHashes, nil
+}
+
+func (c *ChainStore) PersistAddressTransaction(txn *Transaction, height uint32, spentOutputs map[OutPoint]*SpentOutput) error {
+	programHashes, err := getTxAddresses(txn, spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	txId := txn.Hash()
+	for _, programHash := range programHashes {
+		c.BatchPut(getAddressTxKey(programHash, height, txId), []byte{byte(ValueExist)})
+	}
+	return nil
+}
+
+func (c *ChainStore) RollbackAddressTransaction(txn *Transaction, height uint32, spentOutputs map[OutPoint]*SpentOutput) error {
+	programHashes, err := getTxAddresses(txn, spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	txId := txn.Hash()
+	for _, programHash := range programHashes {
+		c.BatchDelete(getAddressTxKey(programHash, height, txId))
+	}
+	return nil
+}
+
+// GetAddressTransactions returns at most limit entries of the address
+// transaction history in height order, skipping the first skip entries.
+// A limit of zero means no limit. The entries are not counted, so the history
+// is iterated only up to the requested page.
+func (c *ChainStore) GetAddressTransactions(programHash Uint168, skip, limit uint32) ([]*AddressTx, error) {
+	prefix := []byte{byte(IX_Address_Tx)}
+	prefix = append(prefix, programHash.Bytes()...)
+
+	txs := make([]*AddressTx, 0)
+	var skipped uint32
+	iter := c.NewIterator(prefix)
+	defer iter.Release()
+	for iter.Next() {
+		if skipped < skip {
+			skipped++
+			continue
+		}
+		if limit > 0 && uint32(len(txs)) >= limit {
+			break
+		}
+
+		addressTx, err := parseAddressTxKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, addressTx)
+	}
+
+	return txs, nil
+}
@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainStore_AddressTransactions(t *testing.T) {
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	// each block pays a coinbase output to the address, and spends the
+	// output of the previous block to the other address
+	address := common.Uint168{0x56, 0x78}
+	other := common.Uint168{0x9a, 0xbc}
+	assetID := genesis.Transactions[1].Hash()
+	var blocks []*core.Block
+	prev := genesis
+	for height := uint32(1); height <= 4; height++ {
+		coinbase := NewCoinBaseTransaction(&core.PayloadCoinBase{}, height)
+		coinbase.Outputs = []*core.Output{{AssetID: assetID, Value: 100, ProgramHash: address}}
+		block := &core.Block{
+			Header:       core.Header{Height: height, Previous: prev.Hash()},
+			Transactions: []*core.Transaction{coinbase},
+		}
+		if height > 1 {
+			spender := newSpendTransaction(core.OutPoint{TxID: prev.Transactions[0].Hash(), Index: 0})
+			spender.Outputs = []*core.Output{{AssetID: assetID, Value: 100, ProgramHash: other}}
+			block.Transactions = append(block.Transactions, spender)
+		}
+		if !assert.NoError(t, store.persist(block)) {
+			return
+		}
+		blocks = append(blocks, block)
+		prev = block
+	}
+
+	heights := func(txs []*AddressTx) []uint32 {
+		heights := make([]uint32, 0, len(txs))
+		for _, tx := range txs {
+			heights = append(heights, tx.Height)
+		}
+		return heights
+	}
+
+	// the entries are in height order
+	all, err := store.GetAddressTransactions(address, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 2, 3, 3, 4, 4}, heights(all))
+	assert.Equal(t, blocks[0].Transactions[0].Hash(), all[0].TxId)
+	txs, err := store.GetAddressTransactions(other, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3, 4}, heights(txs))
+
+	// the pages of the history, the last page is not full
+	var paged []*AddressTx
+	for skip := uint32(0); ; skip += 3 {
+		page, err := store.GetAddressTransactions(address, skip, 3)
+		if !assert.NoError(t, err) {
+			return
+		}
+		paged = append(paged, page...)
+		if len(page) < 3 {
+			assert.Len(t, page, 1)
+			break
+		}
+	}
+	assert.Equal(t, all, paged)
+	txs, err = store.GetAddressTransactions(address, 7, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+
+	// the entries of the block are removed when it is rolled back
+	if !assert.NoError(t, store.commitBlockSteps(blocks[3], blockRollbackSteps)) {
+		return
+	}
+	txs, err = store.GetAddressTransactions(address, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 2, 3, 3}, heights(txs))
+	txs, err = store.GetAddressTransactions(other, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3}, heights(txs))
+}
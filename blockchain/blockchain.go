@@ -52,9 +52,28 @@ type Blockchain struct {
 	BCEvents       *events.Event
 	mutex          sync.RWMutex
 	AssetID        Uint256
+
+	// TrustedHeight is the height not higher than which the transaction
+	// signatures in blocks are not checked, used when importing blocks
+	// from a trusted block file.
+	TrustedHeight uint32
+
+	checkpoints []config.Checkpoint
+
+	// deploymentStates caches the states of the deployments by the last
+	// block of the windows.
+	deployments      []config.ConsensusDeployment
+	deploymentWindow uint32
+	deploymentStates []map[Uint256]ThresholdState
+	deploymentLock   sync.Mutex
 }
 
 func NewBlockchain(height uint32) *Blockchain {
+	deployments := config.Parameters.ChainParam.Deployments
+	deploymentStates := make([]map[Uint256]ThresholdState, len(deployments))
+	for i := range deploymentStates {
+		deploymentStates[i] = make(map[Uint256]ThresholdState)
+	}
 	return &Blockchain{
 		BlockHeight:  height,
 		Root:         nil,
@@ -69,6 +88,11 @@ func NewBlockchain(height uint32) *Blockchain {
 
 		BCEvents: events.NewEvent(),
 		AssetID:  EmptyHash,
+
+		checkpoints:      config.Parameters.ChainParam.Checkpoints,
+		deployments:      deployments,
+		deploymentWindow: blocksPerRetarget,
+		deploymentStates: deploymentStates,
 	}
 }
 
@@ -813,6 +837,11 @@ func (bc *Blockchain) ConnectBlock(node *BlockNode, block *Block) error {
 		return err
 	}
 
+	// The signatures of the transactions are not verified again.
+	for _, tx := range block.Transactions {
+		DefaultSigCache.RemoveTransaction(tx)
+	}
+
 	// Add the new node to the memory main chain indices for faster
 	// lookups.
 	node.InMainChain = true
@@ -865,6 +894,13 @@ func (bc *Blockchain) maybeAcceptBlock(block *Block) (bool, error) {
 		return false, fmt.Errorf("wrong block height!")
 	}
 
+	// The block must match the checkpoints and must not fork the chain
+	// below the latest checkpoint.
+	err = bc.checkBlockCheckpoint(blockHeight, block.Hash())
+	if err != nil {
+		return false, err
+	}
+
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
 	err = PowCheckBlockContext(block, prevNode, DefaultLedger)
@@ -992,9 +1028,23 @@ func (bc *Blockchain) ConnectBestChain(node *BlockNode, block *Block) (bool, err
 	//	fmt.Println("attach", n.Hash)
 	//}
 
+	// The blocks of the checkpoints can not be disconnected.
+	err := bc.checkReorganizeCheckpoint(detachNodes)
+	if err != nil {
+		return false, err
+	}
+
+	// The pruned blocks can not be disconnected.
+	if detachNodes.Len() > 0 {
+		lowest := detachNodes.Back().Value.(*BlockNode)
+		if prunedHeight := DefaultLedger.Store.GetPrunedHeight(); lowest.Height < prunedHeight {
+			return false, fmt.Errorf("reorganize disconnects pruned block at height %d", lowest.Height)
+		}
+	}
+
 	// Reorganize the chain.
 	log.Infof("REORGANIZE: Block %v is causing a reorganize.", node.Hash)
-	err := bc.ReorganizeChain(detachNodes, attachNodes)
+	err = bc.ReorganizeChain(detachNodes, attachNodes)
 	if err != nil {
 		return false, err
 	}
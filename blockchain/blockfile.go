@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// MaxBlockFileRecordSize limits the size of a block read from a block file,
+// to avoid allocating a huge buffer for a corrupted length.
+const MaxBlockFileRecordSize = 8000000
+
+// Block file is a sequence of records, each record is
+// magic(uint32) || length(uint32) || serialized block
+// the magic is the network magic of the node which exported the file, so a
+// file exported from a different network can not be imported.
+
+// ExportBlocks writes the blocks from startHeight to endHeight (both
+// included) in height order to w, and returns the number of blocks written.
+func ExportBlocks(store IChainStore, w io.Writer, magic, startHeight, endHeight uint32) (uint32, error) {
+	var count uint32
+	buf := new(bytes.Buffer)
+	for height := startHeight; height <= endHeight; height++ {
+		hash, err := store.GetBlockHash(height)
+		if err != nil {
+			return count, fmt.Errorf("get block hash at height %d failed: %s", height, err)
+		}
+		block, err := store.GetBlock(hash)
+		if err != nil {
+			return count, fmt.Errorf("get block at height %d failed: %s", height, err)
+		}
+
+		buf.Reset()
+		if err := block.Serialize(buf); err != nil {
+			return count, err
+		}
+		if err := WriteUint32(w, magic); err != nil {
+			return count, err
+		}
+		if err := WriteUint32(w, uint32(buf.Len())); err != nil {
+			return count, err
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return count, err
+		}
+		count++
+
+		if count%10000 == 0 {
+			log.Infof("[ExportBlocks] exported %d blocks, height %d", count, height)
+		}
+	}
+
+	return count, nil
+}
+
+// ReadBlockRecord reads a block record from r, io.EOF is returned when there
+// is no more record.
+func ReadBlockRecord(r io.Reader, magic uint32) (*Block, error) {
+	// read the record header with io.ReadFull, a buffered reader may return
+	// less bytes than requested at the end of its buffer
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	fileMagic := binary.LittleEndian.Uint32(header[:4])
+	if fileMagic != magic {
+		return nil, fmt.Errorf("unmatched magic %d, expect %d", fileMagic, magic)
+	}
+
+	length := binary.LittleEndian.Uint32(header[4:])
+	if length > MaxBlockFileRecordSize {
+		return nil, fmt.Errorf("block record size %d exceeds limit", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	block := new(Block)
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// ImportBlocks reads the blocks from r and adds them to the block chain, the
+// blocks already in the chain are skipped. The transaction signatures of
+// blocks not higher than trustedHeight are not checked. It returns the number
+// of blocks imported.
+func (bc *Blockchain) ImportBlocks(r io.Reader, magic, trustedHeight uint32) (uint32, error) {
+	bc.TrustedHeight = trustedHeight
+	defer func() { bc.TrustedHeight = 0 }()
+
+	var count uint32
+	for {
+		block, err := ReadBlockRecord(r, magic)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		hash := block.Hash()
+		if bc.BlockExists(&hash) {
+			continue
+		}
+
+		_, isOrphan, err := bc.AddBlock(block)
+		if err != nil {
+			return count, fmt.Errorf("import block at height %d failed: %s", block.Header.Height, err)
+		}
+		if isOrphan {
+			return count, errors.New("blocks in file are not continuous with the chain")
+		}
+		count++
+
+		if count%10000 == 0 {
+			log.Infof("[ImportBlocks] imported %d blocks, height %d", count, block.Header.Height)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportBlocks(t *testing.T) {
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	count, err := ExportBlocks(store, buf, 1234, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	// blocks not in store can not be exported
+	_, err = ExportBlocks(store, new(bytes.Buffer), 1234, 0, 1)
+	assert.Error(t, err)
+
+	data := buf.Bytes()
+	block, err := ReadBlockRecord(bytes.NewReader(data), 1234)
+	if assert.NoError(t, err) {
+		assert.Equal(t, genesis.Hash(), block.Hash())
+	}
+
+	// end of file
+	r := bytes.NewReader(data)
+	ReadBlockRecord(r, 1234)
+	_, err = ReadBlockRecord(r, 1234)
+	assert.Equal(t, io.EOF, err)
+
+	// file of another network
+	_, err = ReadBlockRecord(bytes.NewReader(data), 4321)
+	assert.Error(t, err)
+
+	// truncated record
+	_, err = ReadBlockRecord(bytes.NewReader(data[:len(data)-1]), 1234)
+	assert.Error(t, err)
+}
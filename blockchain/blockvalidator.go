@@ -10,6 +10,7 @@ import (
 	"github.com/elastos/Elastos.ELA/config"
 	. "github.com/elastos/Elastos.ELA/core"
 	. "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/log"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
@@ -93,7 +94,7 @@ func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeS
 		existingTxIds[txId] = struct{}{}
 
 		// Check for transaction sanity
-		if errCode := CheckTransactionSanity(version, txn); errCode != Success {
+		if errCode := CheckTransactionSanity(version, txn, block.Height); errCode != Success {
 			return errors.New("CheckTransactionSanity failed when verifiy block")
 		}
 
@@ -136,10 +137,23 @@ func CheckBlockContext(block *Block) error {
 	var rewardInCoinbase = Fixed64(0)
 	var totalTxFee = Fixed64(0)
 
+	checkSignature := block.Header.Height > DefaultLedger.Blockchain.TrustedHeight
+	// a transaction can spend the outputs of the previous transactions in
+	// the block except the coinbase, which is locked, once the chained
+	// transactions are activated
+	chained := IsChainedTxActive(block.Height)
+	unconfirmed := make(TxMap)
+	// the signatures are checked concurrently after the other rules
+	var sigChecks []sigCheck
 	for index, tx := range block.Transactions {
-		if errCode := CheckTransactionContext(tx); errCode != Success {
+		references, ruleErr := checkTransactionContextReferences(tx, unconfirmed, false, block.Height)
+		if ruleErr != nil {
+			log.Warn(ruleErr)
 			return errors.New("CheckTransactionContext failed when verify block")
 		}
+		if checkSignature && references != nil {
+			sigChecks = append(sigChecks, sigCheck{tx: tx, references: references, height: block.Height})
+		}
 
 		if index == 0 {
 			// Calculate reward in coinbase
@@ -149,13 +163,21 @@ func CheckBlockContext(block *Block) error {
 			continue
 		}
 		// Calculate transaction fee
-		totalTxFee += GetTxFee(tx, DefaultLedger.Blockchain.AssetID)
+		totalTxFee += GetChainedTxFee(tx, DefaultLedger.Blockchain.AssetID, unconfirmed)
+		if chained {
+			unconfirmed[tx.Hash()] = tx
+		}
 	}
 
 	// Reward in coinbase must match inflation 4% per year
 	if rewardInCoinbase-totalTxFee != RewardAmountPerBlock {
 		return errors.New("reward amount in coinbase not correct")
 	}
+
+	if err := checkSignatures(sigChecks, sigCheckWorkers()); err != nil {
+		log.Warn("[CheckTransactionSignature] ", err)
+		return errors.New("CheckTransactionSignature failed when verify block")
+	}
 	return nil
 }
 
@@ -186,7 +208,7 @@ func PowCheckBlockContext(block *Block, prevNode *BlockNode, ledger *Ledger) err
 	}
 
 	for _, tx := range block.Transactions[1:] {
-		if !IsFinalizedTransaction(tx, block.Height) {
+		if !IsFinalizedTransaction(tx, block.Height, medianTime) {
 			return errors.New("block contains unfinalized transaction")
 		}
 	}
@@ -217,15 +239,48 @@ func CheckProofOfWork(header *Header, powLimit *big.Int) error {
 	return nil
 }
 
-func IsFinalizedTransaction(msgTx *Transaction, blockHeight uint32) bool {
+// LockTimeThreshold is the lock time below which it is a block height, and
+// not below which it is a Unix timestamp after the time lock is activated.
+const LockTimeThreshold = 500000000
+
+// isTimeLockActive returns if the lock times not below LockTimeThreshold are
+// Unix timestamps at the block height.
+func isTimeLockActive(blockHeight uint32) bool {
+	return blockHeight >= config.Parameters.ChainParam.TimeLockHeight
+}
+
+// IsChainedTxActive returns if a transaction in the block of the height can
+// spend the outputs of the previous transactions in the block.
+func IsChainedTxActive(blockHeight uint32) bool {
+	return blockHeight >= config.Parameters.ChainParam.ChainedTxHeight
+}
+
+// isHTLCActive returns if the HTLC outputs and programs are allowed in the
+// block of the height.
+func isHTLCActive(blockHeight uint32) bool {
+	return blockHeight >= config.Parameters.ChainParam.HTLCHeight
+}
+
+// isLockTimeReached returns if the lock time is passed by the block of the
+// height, the median time is the median time past of the previous blocks.
+func isLockTimeReached(lockTime, blockHeight uint32, medianTime time.Time) bool {
+	if lockTime >= LockTimeThreshold && isTimeLockActive(blockHeight) {
+		return int64(lockTime) < medianTime.Unix()
+	}
+	return lockTime < blockHeight
+}
+
+// IsFinalizedTransaction returns if the transaction can be included in the
+// block of the height, the median time is the median time past of the
+// previous blocks.
+func IsFinalizedTransaction(msgTx *Transaction, blockHeight uint32, medianTime time.Time) bool {
 	// Lock time of zero means the transaction is finalized.
 	lockTime := msgTx.LockTime
 	if lockTime == 0 {
 		return true
 	}
 
-	//FIXME only height
-	if lockTime < blockHeight {
+	if isLockTimeReached(lockTime, blockHeight, medianTime) {
 		return true
 	}
 
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/elastos/Elastos.ELA/core"
 	"github.com/elastos/Elastos.ELA/log"
@@ -84,3 +86,85 @@ func TestCheckBlockSanity(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestIsFinalizedTransaction(t *testing.T) {
+	timeLockHeight := config.Parameters.ChainParam.TimeLockHeight
+	defer func() { config.Parameters.ChainParam.TimeLockHeight = timeLockHeight }()
+	config.Parameters.ChainParam.TimeLockHeight = 100
+
+	medianTime := time.Unix(1535000000, 0)
+	tx := newSpendTransaction(core.OutPoint{TxID: common.Uint256{1}})
+	tx.Inputs[0].Sequence = math.MaxUint32 - 1
+
+	// a lock time of zero
+	assert.True(t, IsFinalizedTransaction(tx, 10, medianTime))
+
+	// a height lock time
+	tx.LockTime = 50
+	assert.False(t, IsFinalizedTransaction(tx, 50, medianTime))
+	assert.True(t, IsFinalizedTransaction(tx, 51, medianTime))
+	assert.True(t, IsFinalizedTransaction(tx, 200, medianTime))
+
+	// a time lock time is compared with the median time past after the
+	// time lock is activated
+	tx.LockTime = uint32(medianTime.Unix()) - 1
+	assert.False(t, IsFinalizedTransaction(tx, 99, medianTime))
+	assert.True(t, IsFinalizedTransaction(tx, 100, medianTime))
+	tx.LockTime = uint32(medianTime.Unix())
+	assert.False(t, IsFinalizedTransaction(tx, 100, medianTime))
+	assert.True(t, IsFinalizedTransaction(tx, 100, medianTime.Add(time.Second)))
+}
+
+func TestCheckBlockContext_ChainedTransactions(t *testing.T) {
+	ledger := DefaultLedger
+	defer func() { DefaultLedger = ledger }()
+	chainedTxHeight := config.Parameters.ChainParam.ChainedTxHeight
+	defer func() { config.Parameters.ChainParam.ChainedTxHeight = chainedTxHeight }()
+
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, Init(store)) {
+		return
+	}
+	DefaultLedger.Blockchain.TrustedHeight = math.MaxUint32
+	assetID := DefaultLedger.Blockchain.AssetID
+
+	// the deposit of block 1 is spent by tx1, and tx2 spends the output of
+	// tx1 in block 2
+	deposit := &core.Transaction{
+		TxType:     core.TransferAsset,
+		Payload:    &core.PayloadTransferAsset{},
+		Attributes: []*core.Attribute{},
+		Outputs:    []*core.Output{{AssetID: assetID, Value: 100 * common.Fixed64(ELA)}},
+	}
+	block1 := &core.Block{
+		Header: core.Header{Height: 1, Previous: genesis.Hash()},
+		Transactions: []*core.Transaction{
+			NewCoinBaseTransaction(&core.PayloadCoinBase{}, 1), deposit,
+		},
+	}
+	if !assert.NoError(t, store.persist(block1)) {
+		return
+	}
+
+	fee := common.Fixed64(config.Parameters.PowConfiguration.MinTxFee)
+	tx1 := newSpendTransaction(core.OutPoint{TxID: deposit.Hash()})
+	tx1.Outputs = []*core.Output{{AssetID: assetID, Value: deposit.Outputs[0].Value - fee}}
+	tx2 := newSpendTransaction(core.OutPoint{TxID: tx1.Hash()})
+	tx2.Outputs = []*core.Output{{AssetID: assetID, Value: tx1.Outputs[0].Value - fee}}
+	coinbase := NewCoinBaseTransaction(&core.PayloadCoinBase{}, 2)
+	coinbase.Outputs = []*core.Output{{AssetID: assetID, Value: RewardAmountPerBlock + 2*fee}}
+	block2 := &core.Block{
+		Header:       core.Header{Height: 2, Previous: block1.Hash()},
+		Transactions: []*core.Transaction{coinbase, tx1, tx2},
+	}
+
+	// tx2 can not spend the output of tx1 before the activation
+	config.Parameters.ChainParam.ChainedTxHeight = 3
+	assert.False(t, IsChainedTxActive(2))
+	assert.Error(t, CheckBlockContext(block2))
+
+	config.Parameters.ChainParam.ChainedTxHeight = 2
+	assert.True(t, IsChainedTxActive(2))
+	assert.NoError(t, CheckBlockContext(block2))
+}
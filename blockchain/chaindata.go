@@ -94,6 +94,26 @@ func (c *ChainStore) PersistCurrentBlock(b *Block) error {
 	return nil
 }
 
+// getStoredCurrentBlock reads the current block hash and height from store,
+// it does not rely on the height loaded by InitWithGenesisBlock.
+func (c *ChainStore) getStoredCurrentBlock() (Uint256, uint32, error) {
+	data, err := c.Get([]byte{byte(SYS_CurrentBlock)})
+	if err != nil {
+		return Uint256{}, 0, err
+	}
+
+	r := bytes.NewReader(data)
+	var hash Uint256
+	if err := hash.Deserialize(r); err != nil {
+		return Uint256{}, 0, err
+	}
+	height, err := ReadUint32(r)
+	if err != nil {
+		return Uint256{}, 0, err
+	}
+	return hash, height, nil
+}
+
 func (c *ChainStore) RollbackCurrentBlock(b *Block) error {
 	key := new(bytes.Buffer)
 	key.WriteByte(byte(SYS_CurrentBlock))
@@ -112,6 +132,11 @@ func (c *ChainStore) RollbackCurrentBlock(b *Block) error {
 }
 
 func (c *ChainStore) PersistUnspendUTXOs(b *Block) error {
+	spentOutputs, err := c.getBlockSpentOutputs(b)
+	if err != nil {
+		return err
+	}
+
 	unspendUTXOs := make(map[Uint168]map[Uint256]map[uint32][]*UTXO)
 	curHeight := b.Header.Height
 
@@ -152,12 +177,14 @@ func (c *ChainStore) PersistUnspendUTXOs(b *Block) error {
 
 		if !txn.IsCoinBaseTx() {
 			for _, input := range txn.Inputs {
-				referTxn, height, err := c.GetTransaction(input.Previous.TxID)
-				if err != nil {
-					return err
+				// the output may be created by a previous transaction in the block
+				spent, ok := spentOutputs[input.Previous]
+				if !ok {
+					return errors.New(fmt.Sprintf("[persist] UTXOs NOT find spent output by txid: %x, index: %d.", input.Previous.TxID, input.Previous.Index))
 				}
+				height := spent.Height
 				index := input.Previous.Index
-				referTxnOutput := referTxn.Outputs[index]
+				referTxnOutput := spent.Output
 				programHash := referTxnOutput.ProgramHash
 				assetID := referTxnOutput.AssetID
 
@@ -179,7 +206,7 @@ func (c *ChainStore) PersistUnspendUTXOs(b *Block) error {
 				flag := false
 				listnum := len(unspendUTXOs[programHash][assetID][height])
 				for i := 0; i < listnum; i++ {
-					if unspendUTXOs[programHash][assetID][height][i].TxId.IsEqual(referTxn.Hash()) && unspendUTXOs[programHash][assetID][height][i].Index == uint32(index) {
+					if unspendUTXOs[programHash][assetID][height][i].TxId.IsEqual(input.Previous.TxID) && unspendUTXOs[programHash][assetID][height][i].Index == uint32(index) {
 						unspendUTXOs[programHash][assetID][height][i] = unspendUTXOs[programHash][assetID][height][listnum-1]
 						unspendUTXOs[programHash][assetID][height] = unspendUTXOs[programHash][assetID][height][:listnum-1]
 						flag = true
@@ -187,7 +214,7 @@ func (c *ChainStore) PersistUnspendUTXOs(b *Block) error {
 					}
 				}
 				if !flag {
-					return errors.New(fmt.Sprintf("[persist] UTXOs NOT find UTXO by txid: %x, index: %d.", referTxn.Hash(), index))
+					return errors.New(fmt.Sprintf("[persist] UTXOs NOT find UTXO by txid: %x, index: %d.", input.Previous.TxID, index))
 				}
 			}
 		}
@@ -210,9 +237,17 @@ func (c *ChainStore) PersistUnspendUTXOs(b *Block) error {
 }
 
 func (c *ChainStore) RollbackUnspendUTXOs(b *Block) error {
+	spentOutputs, err := c.getBlockSpentOutputs(b)
+	if err != nil {
+		return err
+	}
+
 	unspendUTXOs := make(map[Uint168]map[Uint256]map[uint32][]*UTXO)
 	height := b.Header.Height
-	for _, txn := range b.Transactions {
+	// the transactions are rolled back in reverse order, so the outputs
+	// spent by the later transactions in the block are restored first
+	for i := len(b.Transactions) - 1; i >= 0; i-- {
+		txn := b.Transactions[i]
 		if txn.TxType == RegisterAsset {
 			continue
 		}
@@ -238,24 +273,23 @@ func (c *ChainStore) RollbackUnspendUTXOs(b *Block) error {
 				Index: uint32(index),
 				Value: value,
 			}
-			var position int
-			for i, unspend := range unspendUTXOs[programHash][assetID][height] {
+			for j, unspend := range unspendUTXOs[programHash][assetID][height] {
 				if unspend.TxId == u.TxId && unspend.Index == u.Index {
-					position = i
+					unspendUTXOs[programHash][assetID][height] = append(unspendUTXOs[programHash][assetID][height][:j], unspendUTXOs[programHash][assetID][height][j+1:]...)
 					break
 				}
 			}
-			unspendUTXOs[programHash][assetID][height] = append(unspendUTXOs[programHash][assetID][height][:position], unspendUTXOs[programHash][assetID][height][position+1:]...)
 		}
 
 		if !txn.IsCoinBaseTx() {
 			for _, input := range txn.Inputs {
-				referTxn, hh, err := c.GetTransaction(input.Previous.TxID)
-				if err != nil {
-					return err
+				spent, ok := spentOutputs[input.Previous]
+				if !ok {
+					return errors.New(fmt.Sprintf("[rollback] UTXOs NOT find spent output by txid: %x, index: %d.", input.Previous.TxID, input.Previous.Index))
 				}
+				hh := spent.Height
 				index := input.Previous.Index
-				referTxnOutput := referTxn.Outputs[index]
+				referTxnOutput := spent.Output
 				programHash := referTxnOutput.ProgramHash
 				assetID := referTxnOutput.AssetID
 				if _, ok := unspendUTXOs[programHash]; !ok {
@@ -271,7 +305,7 @@ func (c *ChainStore) RollbackUnspendUTXOs(b *Block) error {
 					}
 				}
 				u := UTXO{
-					TxId:  referTxn.Hash(),
+					TxId:  input.Previous.TxID,
 					Index: uint32(index),
 					Value: referTxnOutput.Value,
 				}
@@ -296,10 +330,21 @@ func (c *ChainStore) RollbackUnspendUTXOs(b *Block) error {
 }
 
 func (c *ChainStore) PersistTransactions(b *Block) error {
+	spentOutputs, err := c.getBlockSpentOutputs(b)
+	if err != nil {
+		return err
+	}
+
 	for _, txn := range b.Transactions {
 		if err := c.PersistTransaction(txn, b.Header.Height); err != nil {
 			return err
 		}
+		if err := c.PersistAddressTransaction(txn, b.Header.Height, spentOutputs); err != nil {
+			return err
+		}
+		if err := c.PersistSpentBy(txn, b.Header.Height); err != nil {
+			return err
+		}
 		if txn.TxType == RegisterAsset {
 			regPayload := txn.Payload.(*PayloadRegisterAsset)
 			if err := c.PersistAsset(txn.Hash(), regPayload.Asset); err != nil {
@@ -317,10 +362,21 @@ func (c *ChainStore) PersistTransactions(b *Block) error {
 }
 
 func (c *ChainStore) RollbackTransactions(b *Block) error {
+	spentOutputs, err := c.getBlockSpentOutputs(b)
+	if err != nil {
+		return err
+	}
+
 	for _, txn := range b.Transactions {
 		if err := c.RollbackTransaction(txn); err != nil {
 			return err
 		}
+		if err := c.RollbackAddressTransaction(txn, b.Header.Height, spentOutputs); err != nil {
+			return err
+		}
+		if err := c.RollbackSpentBy(txn); err != nil {
+			return err
+		}
 		if txn.TxType == RegisterAsset {
 			if err := c.RollbackAsset(txn.Hash()); err != nil {
 				return err
@@ -427,12 +483,14 @@ func (c *ChainStore) PersistUnspend(b *Block) error {
 func (c *ChainStore) RollbackUnspend(b *Block) error {
 	unspentPrefix := []byte{byte(IX_Unspent)}
 	unspents := make(map[Uint256][]uint16)
+	blockTxs := make(map[Uint256]struct{}, len(b.Transactions))
 	for _, txn := range b.Transactions {
 		if txn.TxType == RegisterAsset {
 			continue
 		}
 		// remove all utxos created by this transaction
 		txnHash := txn.Hash()
+		blockTxs[txnHash] = struct{}{}
 		c.BatchDelete(append(unspentPrefix, txnHash.Bytes()...))
 		if !txn.IsCoinBaseTx() {
 
@@ -455,6 +513,11 @@ func (c *ChainStore) RollbackUnspend(b *Block) error {
 	}
 
 	for txhash, value := range unspents {
+		// the outputs of the transactions in the block are removed
+		if _, ok := blockTxs[txhash]; ok {
+			continue
+		}
+
 		key := new(bytes.Buffer)
 		key.WriteByte(byte(IX_Unspent))
 		txhash.Serialize(key)
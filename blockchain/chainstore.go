@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"container/list"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/elastos/Elastos.ELA/config"
 	. "github.com/elastos/Elastos.ELA/core"
 	"github.com/elastos/Elastos.ELA/events"
 	"github.com/elastos/Elastos.ELA/log"
@@ -23,7 +25,7 @@ type persistTask interface{}
 
 type rollbackBlockTask struct {
 	blockHash Uint256
-	reply     chan bool
+	reply     chan error
 }
 
 type persistBlockTask struct {
@@ -44,14 +46,44 @@ type ChainStore struct {
 
 	currentBlockHeight uint32
 	storedHeaderCount  uint32
+
+	// pruneBlocks is the number of recent blocks whose full data is kept,
+	// zero means the store is not pruned.
+	pruneBlocks uint32
+
+	// blockSpent is the outputs spent by the block being committed by
+	// commitBlockSteps, so the steps do not look them up again. It is only
+	// used by the goroutine committing the block.
+	blockSpent *blockSpentOutputs
 }
 
+// NewChainStore opens the chain store in the chain directory of the active
+// network, a store created for another network magic is refused.
 func NewChainStore() (IChainStore, error) {
-	st, err := NewLevelDB("Chain")
+	if err := checkPruneBlocks(config.Parameters.PruneBlocks); err != nil {
+		return nil, err
+	}
+	st, err := NewLevelDB(config.Parameters.ChainDir())
 	if err != nil {
 		return nil, err
 	}
 
+	store := newChainStore(st)
+	if err := store.checkNetworkMagic(config.Parameters.Magic); err != nil {
+		store.Close()
+		return nil, err
+	}
+	store.pruneBlocks = config.Parameters.PruneBlocks
+	return store, nil
+}
+
+// NewChainStoreWithStore creates a ChainStore on top of the given IStore,
+// such as a memory store created by NewMemLevelDB.
+func NewChainStoreWithStore(st IStore) IChainStore {
+	return newChainStore(st)
+}
+
+func newChainStore(st IStore) *ChainStore {
 	store := &ChainStore{
 		IStore:             st,
 		headerIndex:        map[uint32]Uint256{},
@@ -65,7 +97,7 @@ func NewChainStore() (IChainStore, error) {
 
 	go store.loop()
 
-	return store, nil
+	return store
 }
 
 func (c *ChainStore) Close() {
@@ -75,6 +107,33 @@ func (c *ChainStore) Close() {
 	c.IStore.Close()
 }
 
+// checkNetworkMagic returns an error if the store is created for another
+// network magic, a store which is not marked with a magic passes the check.
+func (c *ChainStore) checkNetworkMagic(magic uint32) error {
+	data, err := c.Get([]byte{byte(CFG_NetworkMagic)})
+	if err != nil {
+		return nil
+	}
+	storeMagic, err := ReadUint32(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if storeMagic != magic {
+		return fmt.Errorf("store is created for network magic %d, not %d", storeMagic, magic)
+	}
+	return nil
+}
+
+// key: CFG_NetworkMagic
+// value: magic
+func (c *ChainStore) putNetworkMagic(magic uint32) error {
+	value := new(bytes.Buffer)
+	if err := WriteUint32(value, magic); err != nil {
+		return err
+	}
+	return c.Put([]byte{byte(CFG_NetworkMagic)}, value.Bytes())
+}
+
 func (c *ChainStore) loop() {
 	for {
 		select {
@@ -87,8 +146,7 @@ func (c *ChainStore) loop() {
 				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
 				log.Debugf("handle block exetime: %g num transactions:%d", tcall, len(task.block.Transactions))
 			case *rollbackBlockTask:
-				c.handleRollbackBlockTask(task.blockHash)
-				task.reply <- true
+				task.reply <- c.handleRollbackBlockTask(task.blockHash)
 				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
 				log.Debugf("handle block rollback exetime: %g", tcall)
 			}
@@ -116,12 +174,7 @@ func (c *ChainStore) clearCache(b *Block) {
 
 func (c *ChainStore) InitWithGenesisBlock(genesisBlock *Block) (uint32, error) {
 	prefix := []byte{byte(CFG_Version)}
-	version, err := c.Get(prefix)
-	if err != nil {
-		version = []byte{0x00}
-	}
-
-	if version[0] == 0x00 {
+	if c.getStoreVersion() == 0x00 {
 		// batch delete old data
 		c.NewBatch()
 		iter := c.NewIterator(nil)
@@ -142,12 +195,36 @@ func (c *ChainStore) InitWithGenesisBlock(genesisBlock *Block) (uint32, error) {
 		}
 
 		// put version to db
-		err = c.Put(prefix, []byte{0x01})
+		err = c.Put(prefix, []byte{CurrentStoreVersion})
 		if err != nil {
 			return 0, err
 		}
 	}
 
+	// mark the store with the network magic, a store created by an older
+	// version is marked at its first start up
+	if err := c.checkNetworkMagic(config.Parameters.Magic); err != nil {
+		return 0, err
+	}
+	if err := c.putNetworkMagic(config.Parameters.Magic); err != nil {
+		return 0, err
+	}
+
+	// upgrade the store created by an older version
+	if err := c.migrate(); err != nil {
+		return 0, err
+	}
+
+	// repair the partially written blocks
+	if err := c.recoverStore(); err != nil {
+		return 0, err
+	}
+
+	// prune the blocks if pruning is enabled on a store not pruned
+	if err := c.pruneStore(); err != nil {
+		return 0, err
+	}
+
 	// GenesisBlock should exist in chain
 	// Or the bookkeepers are not consistent with the chain
 	hash := genesisBlock.Hash()
@@ -295,11 +372,9 @@ func (c *ChainStore) GetCurrentBlockHash() Uint256 {
 
 func (c *ChainStore) RollbackBlock(blockHash Uint256) error {
 
-	reply := make(chan bool)
+	reply := make(chan error)
 	c.taskCh <- &rollbackBlockTask{blockHash: blockHash, reply: reply}
-	<-reply
-
-	return nil
+	return <-reply
 }
 
 func (c *ChainStore) GetHeader(hash Uint256) (*Header, error) {
@@ -485,15 +560,56 @@ func (c *ChainStore) GetBlock(hash Uint256) (*Block, error) {
 	return b, nil
 }
 
-func (c *ChainStore) rollback(b *Block) error {
+// blockPersistSteps are the steps to persist a block. All of them write to
+// the same batch which is committed at last, so a block is either fully
+// persisted or not at all.
+var blockPersistSteps = []func(c *ChainStore, b *Block) error{
+	(*ChainStore).PersistTrimmedBlock,
+	(*ChainStore).PersistBlockHash,
+	(*ChainStore).PersistBlockUndo,
+	(*ChainStore).PersistTransactions,
+	(*ChainStore).PersistUnspendUTXOs,
+	(*ChainStore).PersistUnspend,
+	(*ChainStore).PruneBlocks,
+	(*ChainStore).PersistCurrentBlock,
+}
+
+// blockRollbackSteps are the steps to rollback a block, which are committed
+// in one batch like blockPersistSteps.
+var blockRollbackSteps = []func(c *ChainStore, b *Block) error{
+	(*ChainStore).RollbackTrimmedBlock,
+	(*ChainStore).RollbackBlockHash,
+	(*ChainStore).RollbackBlockUndo,
+	(*ChainStore).RollbackTransactions,
+	(*ChainStore).RollbackUnspendUTXOs,
+	(*ChainStore).RollbackUnspend,
+	(*ChainStore).RollbackCurrentBlock,
+}
+
+// commitBlockSteps runs the steps in a new batch and commits it only if all
+// of them succeed, otherwise the batch is dropped.
+func (c *ChainStore) commitBlockSteps(b *Block, steps []func(c *ChainStore, b *Block) error) error {
+	spentOutputs, err := c.loadSpentOutputs(b)
+	if err != nil {
+		return err
+	}
+	c.blockSpent = newBlockSpentOutputs(b.Hash(), spentOutputs)
+	defer func() { c.blockSpent = nil }()
+
 	c.NewBatch()
-	c.RollbackTrimmedBlock(b)
-	c.RollbackBlockHash(b)
-	c.RollbackTransactions(b)
-	c.RollbackUnspendUTXOs(b)
-	c.RollbackUnspend(b)
-	c.RollbackCurrentBlock(b)
-	c.BatchCommit()
+	for _, step := range steps {
+		if err := step(c, b); err != nil {
+			c.NewBatch()
+			return err
+		}
+	}
+	return c.BatchCommit()
+}
+
+func (c *ChainStore) rollback(b *Block) error {
+	if err := c.commitBlockSteps(b, blockRollbackSteps); err != nil {
+		return err
+	}
 
 	DefaultLedger.Blockchain.UpdateBestHeight(b.Header.Height - 1)
 	c.mu.Lock()
@@ -506,26 +622,7 @@ func (c *ChainStore) rollback(b *Block) error {
 }
 
 func (c *ChainStore) persist(b *Block) error {
-	c.NewBatch()
-	if err := c.PersistTrimmedBlock(b); err != nil {
-		return err
-	}
-	if err := c.PersistBlockHash(b); err != nil {
-		return err
-	}
-	if err := c.PersistTransactions(b); err != nil {
-		return err
-	}
-	if err := c.PersistUnspendUTXOs(b); err != nil {
-		return err
-	}
-	if err := c.PersistUnspend(b); err != nil {
-		return err
-	}
-	if err := c.PersistCurrentBlock(b); err != nil {
-		return err
-	}
-	return c.BatchCommit()
+	return c.commitBlockSteps(b, blockPersistSteps)
 }
 
 // can only be invoked by backend write goroutine
@@ -554,13 +651,17 @@ func (c *ChainStore) SaveBlock(b *Block) error {
 	return nil
 }
 
-func (c *ChainStore) handleRollbackBlockTask(blockHash Uint256) {
+func (c *ChainStore) handleRollbackBlockTask(blockHash Uint256) error {
 	block, err := c.GetBlock(blockHash)
 	if err != nil {
 		log.Errorf("block %x can't be found", BytesToHexString(blockHash.Bytes()))
-		return
+		return err
 	}
-	c.rollback(block)
+	if err := c.rollback(block); err != nil {
+		log.Errorf("rollback block %x failed: %s", BytesToHexString(blockHash.Bytes()), err.Error())
+		return err
+	}
+	return nil
 }
 
 func (c *ChainStore) handlePersistBlockTask(b *Block) {
@@ -1,10 +1,11 @@
 package blockchain
 
 import (
-	"container/list"
 	"testing"
 
-	ela "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
 )
@@ -13,29 +14,40 @@ var testChainStore *ChainStore
 var sidechainTxHash common.Uint256
 
 func newTestChainStore() (*ChainStore, error) {
-	// TODO: read config file decide which db to use.
-	st, err := NewLevelDB("Chain_UnitTest")
+	st, err := NewMemLevelDB()
 	if err != nil {
 		return nil, err
 	}
 
-	store := &ChainStore{
-		IStore:             st,
-		headerIndex:        map[uint32]common.Uint256{},
-		headerCache:        map[common.Uint256]*ela.Header{},
-		headerIdx:          list.New(),
-		currentBlockHeight: 0,
-		storedHeaderCount:  0,
-		taskCh:             make(chan persistTask, TaskChanCap),
-		quit:               make(chan chan bool, 1),
-	}
-
-	go store.loop()
+	store := newChainStore(st)
 	store.NewBatch()
 
 	return store, nil
 }
 
+// newGenesisTestStore returns an empty chain store in memory, and the genesis
+// block of a test foundation address which is not persisted yet.
+func newGenesisTestStore(t testing.TB) (*ChainStore, *core.Block) {
+	log.Init(
+		config.Parameters.PrintLevel,
+		config.Parameters.MaxPerLogSize,
+		config.Parameters.MaxLogsSize,
+	)
+	st, err := NewMemLevelDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newChainStore(st)
+
+	FoundationAddress = common.Uint168{0x12, 0x34}
+	genesis, err := GetGenesisBlock()
+	if err != nil {
+		store.Close()
+		t.Fatal(err)
+	}
+	return store, genesis
+}
+
 func TestChainStoreInit(t *testing.T) {
 	// Get new chainstore
 	var err error
@@ -153,3 +165,26 @@ func TestChainStoreDone(t *testing.T) {
 
 	testChainStore.BatchCommit()
 }
+
+func TestChainStore_NetworkMagic(t *testing.T) {
+	store, err := newTestChainStore()
+	if err != nil {
+		t.Fatal("Create chainstore failed")
+	}
+	defer store.Close()
+
+	// a store which is not marked passes the check
+	if err := store.checkNetworkMagic(1234); err != nil {
+		t.Error("Unmarked store should pass the check")
+	}
+
+	if err := store.putNetworkMagic(1234); err != nil {
+		t.Fatal("Put network magic failed")
+	}
+	if err := store.checkNetworkMagic(1234); err != nil {
+		t.Error("Store should pass the check of its own magic")
+	}
+	if err := store.checkNetworkMagic(4321); err == nil {
+		t.Error("Store should not pass the check of another magic")
+	}
+}
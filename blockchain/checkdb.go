@@ -0,0 +1,253 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// MaxCheckDBProblems is the max number of problems reported by CheckDB.
+const MaxCheckDBProblems = 100
+
+// ErrPrunedReindex is returned when the indexes of a pruned store need to be
+// rebuilt, which is not possible without the transactions of the pruned
+// blocks.
+var ErrPrunedReindex = errors.New("the indexes of a pruned store can not be rebuilt, " +
+	"delete the chain data directory and resync the blocks")
+
+// indexPrefixes are the entries which can be rebuilt from the stored blocks.
+var indexPrefixes = []DataEntryPrefix{
+	DATA_BlockUndo,
+	IX_Unspent,
+	IX_Unspent_UTXO,
+	IX_SideChain_Tx,
+	IX_Address_Tx,
+	IX_Spent_By,
+	ST_Info,
+}
+
+// canonicalIndexValue returns the value of an index entry which does not
+// depend on the order the blocks were connected or disconnected.
+func canonicalIndexValue(prefix DataEntryPrefix, value []byte) ([]byte, error) {
+	switch prefix {
+	case IX_Unspent:
+		return canonicalUnspent(value)
+	case IX_Unspent_UTXO:
+		return canonicalUnspentUTXO(value)
+	}
+	return value, nil
+}
+
+// forEachStoredBlock calls handler with every block from the genesis block to
+// the current block, it checks the blocks are linked and their transactions
+// are stored with the right height.
+func (c *ChainStore) forEachStoredBlock(handler func(b *Block) error) error {
+	currentHash, endHeight, err := c.getStoredCurrentBlock()
+	if err != nil {
+		return fmt.Errorf("get current block failed: %s", err)
+	}
+
+	var prevHash Uint256
+	for height := uint32(0); height <= endHeight; height++ {
+		hash, err := c.GetBlockHash(height)
+		if err != nil {
+			return fmt.Errorf("block hash at height %d is missing", height)
+		}
+		block, err := c.GetBlock(hash)
+		if err != nil {
+			return fmt.Errorf("block %s at height %d is missing: %s", hash.String(), height, err)
+		}
+		if block.Header.Height != height {
+			return fmt.Errorf("block %s has height %d, expect %d", hash.String(), block.Header.Height, height)
+		}
+		if height > 0 && !block.Header.Previous.IsEqual(prevHash) {
+			return fmt.Errorf("block %s at height %d is not linked to the previous block", hash.String(), height)
+		}
+		for _, txn := range block.Transactions {
+			if _, txHeight, err := c.GetTransaction(txn.Hash()); err != nil || txHeight != height {
+				return fmt.Errorf("transaction %s of block at height %d is missing", txn.Hash().String(), height)
+			}
+		}
+
+		if err := handler(block); err != nil {
+			return err
+		}
+		prevHash = hash
+
+		if (height+1)%10000 == 0 {
+			log.Infof("processed %d/%d blocks", height+1, endHeight+1)
+		}
+	}
+
+	if !currentHash.IsEqual(prevHash) {
+		return fmt.Errorf("current block %s is not the block at height %d", currentHash.String(), endHeight)
+	}
+	return nil
+}
+
+// CheckDB verifies the stored blocks are complete and linked, and the index
+// entries are consistent with them. The expected index entries are built by
+// persisting the stored blocks into a scratch store on disk in scratchDir,
+// which is deleted afterwards. It returns the problems found, at most
+// MaxCheckDBProblems.
+func (c *ChainStore) CheckDB(scratchDir string) ([]string, error) {
+	if c.GetPrunedHeight() > 0 {
+		return nil, errors.New("a pruned store can not be checked")
+	}
+
+	// the scratch store left by an interrupted check is dropped
+	if err := os.RemoveAll(scratchDir); err != nil {
+		return nil, err
+	}
+	st, err := NewLevelDB(scratchDir)
+	if err != nil {
+		return nil, err
+	}
+	expected := newChainStore(st)
+	defer func() {
+		expected.Close()
+		if err := os.RemoveAll(scratchDir); err != nil {
+			log.Warnf("[CheckDB] remove scratch store %s failed: %s", scratchDir, err)
+		}
+	}()
+
+	log.Info("[CheckDB] checking blocks")
+	err = c.forEachStoredBlock(func(b *Block) error {
+		if err := expected.persist(b); err != nil {
+			return fmt.Errorf("block at height %d can not be persisted: %s", b.Header.Height, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+
+	log.Info("[CheckDB] checking indexes")
+	var problems []string
+	report := func(format string, a ...interface{}) bool {
+		problems = append(problems, fmt.Sprintf(format, a...))
+		return len(problems) < MaxCheckDBProblems
+	}
+	for _, prefix := range indexPrefixes {
+		// the blocks persisted by older versions have no undo record, they
+		// are rolled back by looking up the transactions they spent from
+		allowMissing := prefix == DATA_BlockUndo
+		if !compareIndex(c.IStore, expected.IStore, prefix, allowMissing, report) {
+			break
+		}
+	}
+
+	return problems, nil
+}
+
+// compareIndex reports the differences of the entries with the given prefix
+// between the two stores, the missing entries are not reported if
+// allowMissing is true. It returns false if report asks to stop.
+func compareIndex(actual, expected IStore, prefix DataEntryPrefix, allowMissing bool,
+	report func(format string, a ...interface{}) bool) bool {
+	actualIter := actual.NewIterator([]byte{byte(prefix)})
+	defer actualIter.Release()
+	expectedIter := expected.NewIterator([]byte{byte(prefix)})
+	defer expectedIter.Release()
+
+	hasActual, hasExpected := actualIter.Next(), expectedIter.Next()
+	for hasActual || hasExpected {
+		var cmp int
+		switch {
+		case !hasActual:
+			cmp = 1
+		case !hasExpected:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(actualIter.Key(), expectedIter.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			if !report("unexpected entry %x", actualIter.Key()) {
+				return false
+			}
+			hasActual = actualIter.Next()
+		case cmp > 0:
+			if !allowMissing && !report("missing entry %x", expectedIter.Key()) {
+				return false
+			}
+			hasExpected = expectedIter.Next()
+		default:
+			actualValue, err := canonicalIndexValue(prefix, actualIter.Value())
+			if err != nil {
+				if !report("invalid entry %x: %s", actualIter.Key(), err) {
+					return false
+				}
+			} else if expectedValue, _ := canonicalIndexValue(prefix, expectedIter.Value()); !bytes.Equal(actualValue, expectedValue) {
+				if !report("entry %x has value %x, expect %x", actualIter.Key(), actualValue, expectedValue) {
+					return false
+				}
+			}
+			hasActual, hasExpected = actualIter.Next(), expectedIter.Next()
+		}
+	}
+	return true
+}
+
+// Reindex deletes all the index entries and rebuilds them from the stored
+// blocks, it does not need the network. The stored blocks are checked first,
+// so the indexes are kept if some blocks are broken.
+func (c *ChainStore) Reindex() error {
+	if c.GetPrunedHeight() > 0 {
+		return ErrPrunedReindex
+	}
+
+	log.Info("[Reindex] checking blocks")
+	if err := c.forEachStoredBlock(func(*Block) error { return nil }); err != nil {
+		return err
+	}
+
+	// the pending flag is removed after all indexes are rebuilt, so an
+	// interrupted reindex is continued by the recovery at next start up
+	log.Info("[Reindex] deleting indexes")
+	c.NewBatch()
+	c.BatchPut([]byte{byte(SYS_ReindexPending)}, []byte{byte(ValueExist)})
+	for _, prefix := range indexPrefixes {
+		iter := c.NewIterator([]byte{byte(prefix)})
+		for iter.Next() {
+			c.BatchDelete(iter.Key())
+		}
+		iter.Release()
+	}
+	if err := c.BatchCommit(); err != nil {
+		return err
+	}
+
+	log.Info("[Reindex] rebuilding indexes")
+	err := c.forEachStoredBlock(func(b *Block) error {
+		// the transactions are put again with the same value, which also
+		// builds the asset, sidechain transaction, address and spent-by
+		// indexes, and the undo records are written for all blocks
+		c.NewBatch()
+		if err := c.PersistBlockUndo(b); err != nil {
+			return err
+		}
+		if err := c.PersistTransactions(b); err != nil {
+			return err
+		}
+		if err := c.PersistUnspendUTXOs(b); err != nil {
+			return err
+		}
+		if err := c.PersistUnspend(b); err != nil {
+			return err
+		}
+		return c.BatchCommit()
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Delete([]byte{byte(SYS_ReindexPending)})
+}
@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkTestDB runs CheckDB with a scratch store in a temporary directory.
+func checkTestDB(t testing.TB, store *ChainStore) ([]string, error) {
+	dir, err := ioutil.TempDir("", "checkdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	return store.CheckDB(filepath.Join(dir, "scratch"))
+}
+
+func TestChainStore_CheckDB(t *testing.T) {
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "checkdb")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	scratchDir := filepath.Join(dir, "scratch")
+
+	problems, err := store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	// break the indexes
+	coinbaseHash := genesis.Transactions[0].Hash()
+	store.NewBatch()
+	store.BatchDelete(append([]byte{byte(IX_Unspent)}, coinbaseHash.Bytes()...))
+	store.BatchPut([]byte{byte(IX_SideChain_Tx), 0x01}, []byte{byte(ValueExist)})
+	iter := store.NewIterator([]byte{byte(ST_Info)})
+	for iter.Next() {
+		store.BatchPut(iter.Key(), []byte{0x00})
+	}
+	iter.Release()
+	assert.NoError(t, store.BatchCommit())
+
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 3)
+
+	// the scratch store is deleted after the check
+	_, err = os.Stat(scratchDir)
+	assert.True(t, os.IsNotExist(err))
+
+	// rebuild the indexes
+	assert.NoError(t, store.Reindex())
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	// a missing undo record is written by an older version, but a broken or
+	// stale one is reported
+	undoKey := getBlockUndoKey(genesis.Hash())
+	assert.NoError(t, store.Delete(undoKey))
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.NoError(t, store.Put(undoKey, []byte{0x01}))
+	assert.NoError(t, store.Put(getBlockUndoKey(coinbaseHash), []byte{0x00}))
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 2)
+
+	// the undo records are rebuilt too
+	assert.NoError(t, store.Reindex())
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	_, err = store.GetBlockUndo(genesis.Hash())
+	assert.NoError(t, err)
+
+	// missing block data can not be fixed by reindex
+	store.NewBatch()
+	store.BatchDelete(append([]byte{byte(DATA_Transaction)}, coinbaseHash.Bytes()...))
+	assert.NoError(t, store.BatchCommit())
+	problems, err = store.CheckDB(scratchDir)
+	assert.NoError(t, err)
+	assert.Len(t, problems, 1)
+	assert.Error(t, store.Reindex())
+}
@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA/config"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// Checkpoints returns the checkpoints of the network in height order.
+func (bc *Blockchain) Checkpoints() []config.Checkpoint {
+	return bc.checkpoints
+}
+
+// findPreviousCheckpoint returns the latest checkpoint not higher than the
+// given height, or nil if there is none.
+func (bc *Blockchain) findPreviousCheckpoint(height uint32) *config.Checkpoint {
+	var previous *config.Checkpoint
+	for i := range bc.checkpoints {
+		if bc.checkpoints[i].Height > height {
+			break
+		}
+		previous = &bc.checkpoints[i]
+	}
+	return previous
+}
+
+// checkBlockCheckpoint checks the block at the given height matches the
+// checkpoint at the height, and it does not fork the best chain below the
+// latest checkpoint reached by the best chain.
+func (bc *Blockchain) checkBlockCheckpoint(height uint32, hash Uint256) error {
+	for _, checkpoint := range bc.checkpoints {
+		if checkpoint.Height == height && !checkpoint.Hash.IsEqual(hash) {
+			return fmt.Errorf("block %s at height %d does not match checkpoint %s",
+				hash.String(), height, checkpoint.Hash.String())
+		}
+	}
+
+	// the caller holds the mutex, so the best height is read directly
+	checkpoint := bc.findPreviousCheckpoint(bc.BlockHeight)
+	if checkpoint != nil && height <= checkpoint.Height {
+		return fmt.Errorf("block %s at height %d forks the chain below checkpoint at height %d",
+			hash.String(), height, checkpoint.Height)
+	}
+	return nil
+}
+
+// checkReorganizeCheckpoint refuses a reorganization which disconnects the
+// block of the latest checkpoint reached by the best chain or blocks below it.
+func (bc *Blockchain) checkReorganizeCheckpoint(detachNodes *list.List) error {
+	if detachNodes.Len() == 0 {
+		return nil
+	}
+
+	// the detached nodes are from the best block down to the fork point
+	lowest := detachNodes.Back().Value.(*BlockNode)
+	checkpoint := bc.findPreviousCheckpoint(bc.BlockHeight)
+	if checkpoint != nil && lowest.Height <= checkpoint.Height {
+		return fmt.Errorf("reorganize disconnects block at height %d, not higher than checkpoint at height %d",
+			lowest.Height, checkpoint.Height)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package blockchain
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/config"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockchain_Checkpoints(t *testing.T) {
+	bc := NewBlockchain(0)
+	bc.checkpoints = []config.Checkpoint{
+		{Height: 10, Hash: common.Uint256{10}},
+		{Height: 20, Hash: common.Uint256{20}},
+	}
+
+	assert.Nil(t, bc.findPreviousCheckpoint(9))
+	assert.Equal(t, uint32(10), bc.findPreviousCheckpoint(10).Height)
+	assert.Equal(t, uint32(10), bc.findPreviousCheckpoint(19).Height)
+	assert.Equal(t, uint32(20), bc.findPreviousCheckpoint(25).Height)
+
+	// the block at a checkpoint height must match the checkpoint
+	bc.BlockHeight = 5
+	assert.NoError(t, bc.checkBlockCheckpoint(10, common.Uint256{10}))
+	assert.Error(t, bc.checkBlockCheckpoint(10, common.Uint256{1}))
+	assert.NoError(t, bc.checkBlockCheckpoint(6, common.Uint256{1}))
+	assert.NoError(t, bc.checkBlockCheckpoint(3, common.Uint256{1}))
+
+	// the chain can not fork below the latest reached checkpoint
+	bc.BlockHeight = 25
+	assert.Error(t, bc.checkBlockCheckpoint(20, common.Uint256{20}))
+	assert.Error(t, bc.checkBlockCheckpoint(15, common.Uint256{1}))
+	assert.NoError(t, bc.checkBlockCheckpoint(21, common.Uint256{1}))
+
+	detachNodes := func(heights ...uint32) *list.List {
+		nodes := list.New()
+		for _, height := range heights {
+			nodes.PushBack(&BlockNode{Height: height})
+		}
+		return nodes
+	}
+	assert.NoError(t, bc.checkReorganizeCheckpoint(detachNodes()))
+	assert.NoError(t, bc.checkReorganizeCheckpoint(detachNodes(25, 24, 23, 22, 21)))
+	assert.Error(t, bc.checkReorganizeCheckpoint(detachNodes(25, 24, 23, 22, 21, 20)))
+}
+
+func TestMainNetCheckpoints(t *testing.T) {
+	// the built-in checkpoints of the networks, no block of MainNet or
+	// TestNet is taken as a checkpoint yet
+	builtin := map[string][]config.Checkpoint{
+		"MainNet": {},
+		"TestNet": {},
+		"RegNet":  {},
+	}
+	for name, expected := range builtin {
+		params := config.GetChainParams(name)
+		if !assert.NotNil(t, params, name) {
+			continue
+		}
+		if assert.Len(t, params.Checkpoints, len(expected), name) {
+			for i, checkpoint := range expected {
+				assert.Equal(t, checkpoint, params.Checkpoints[i], name)
+			}
+		}
+	}
+
+	params := config.GetChainParams("MainNet")
+	if !assert.NotNil(t, params) {
+		return
+	}
+	hash := common.Uint256{1, 2, 3}
+	checkpoints, err := config.MergeCheckpoints(params.Checkpoints, []config.CheckpointJSON{
+		{Height: 100, Hash: common.BytesToHexString(common.BytesReverse(hash[:]))},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the checkpoints are in height order, and a header conflicting with any
+	// of them is refused
+	bc := NewBlockchain(0)
+	bc.checkpoints = checkpoints
+	for i, checkpoint := range checkpoints {
+		if i > 0 {
+			assert.True(t, checkpoints[i-1].Height < checkpoint.Height)
+		}
+		assert.NoError(t, bc.checkBlockCheckpoint(checkpoint.Height, checkpoint.Hash))
+		conflicting := checkpoint.Hash
+		conflicting[0] ^= 0xff
+		assert.Error(t, bc.checkBlockCheckpoint(checkpoint.Height, conflicting))
+	}
+	assert.Error(t, bc.checkBlockCheckpoint(100, common.Uint256{3, 2, 1}))
+
+	// a checkpoint set in config.json can not conflict with the network ones
+	_, err = config.MergeCheckpoints(checkpoints, []config.CheckpointJSON{
+		{Height: 100, Hash: common.BytesToHexString(make([]byte, 32))},
+	})
+	assert.Error(t, err)
+	_, err = config.MergeCheckpoints(checkpoints, []config.CheckpointJSON{{Height: 100, Hash: "xyz"}})
+	assert.Error(t, err)
+}
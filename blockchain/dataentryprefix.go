@@ -8,12 +8,15 @@ const (
 	DATA_BlockHash   DataEntryPrefix = 0x00
 	DATA_Header      DataEntryPrefix = 0x01
 	DATA_Transaction DataEntryPrefix = 0x02
+	DATA_BlockUndo   DataEntryPrefix = 0x03
 
 	// INDEX
 	IX_HeaderHashList DataEntryPrefix = 0x80
 	IX_Unspent        DataEntryPrefix = 0x90
 	IX_Unspent_UTXO   DataEntryPrefix = 0x91
 	IX_SideChain_Tx   DataEntryPrefix = 0x92
+	IX_Address_Tx     DataEntryPrefix = 0x93
+	IX_Spent_By       DataEntryPrefix = 0x94
 
 	// ASSET
 	ST_Info DataEntryPrefix = 0xc0
@@ -21,7 +24,11 @@ const (
 	//SYSTEM
 	SYS_CurrentBlock      DataEntryPrefix = 0x40
 	SYS_CurrentBookKeeper DataEntryPrefix = 0x42
+	SYS_ReindexPending    DataEntryPrefix = 0x43
+	SYS_PrunedHeight      DataEntryPrefix = 0x44
 
 	//CONFIG
-	CFG_Version DataEntryPrefix = 0xf0
+	CFG_Version      DataEntryPrefix = 0xf0
+	CFG_Migration    DataEntryPrefix = 0xf1
+	CFG_NetworkMagic DataEntryPrefix = 0xf2
 )
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// MaxFeeEstimateBlocks is the max number of blocks a fee can be
+	// estimated for.
+	MaxFeeEstimateBlocks = 25
+
+	// minFeeBucket is the lower bound fee per KB of the lowest bucket, and
+	// each bucket is feeBucketSpacing times higher than the previous one,
+	// until maxFeeBucket.
+	minFeeBucket     = 100
+	maxFeeBucket     = 100000000
+	feeBucketSpacing = 1.2
+
+	// feeEstimateDecay is how much the history of the confirmations decays
+	// each block, so the estimate follows the recent blocks.
+	feeEstimateDecay = 0.998
+
+	// feeEstimateSuccess is the min rate of the transactions confirmed
+	// within the target blocks for a fee to be estimated.
+	feeEstimateSuccess = 0.85
+
+	// minFeeEstimateData is the min number of the transactions a fee is
+	// estimated with.
+	minFeeEstimateData = 10
+)
+
+// FeeEstimator estimates the fee per KB a transaction pays to be included
+// within a number of blocks. The transactions are grouped in buckets by the
+// fee per KB, and the number of blocks each of them takes from the pool
+// acceptance to the block inclusion is tracked.
+type FeeEstimator struct {
+	sync.Mutex
+	buckets []Fixed64 // lower bound fee per KB of the buckets
+	// confirmed[b][t] is the number of the transactions in bucket b
+	// included within t+1 blocks
+	confirmed [][]float64
+	// total[b] is the number of the transactions in bucket b included
+	total []float64
+}
+
+func NewFeeEstimator() *FeeEstimator {
+	var buckets []Fixed64
+	for fee := float64(minFeeBucket); fee <= maxFeeBucket; fee *= feeBucketSpacing {
+		buckets = append(buckets, Fixed64(fee))
+	}
+	confirmed := make([][]float64, len(buckets))
+	for i := range confirmed {
+		confirmed[i] = make([]float64, MaxFeeEstimateBlocks)
+	}
+	return &FeeEstimator{
+		buckets:   buckets,
+		confirmed: confirmed,
+		total:     make([]float64, len(buckets)),
+	}
+}
+
+// bucketIndex returns the bucket of the fee per KB, the fees lower than the
+// lowest bucket are in it.
+func (e *FeeEstimator) bucketIndex(feePerKB Fixed64) int {
+	index := 0
+	for i, bucket := range e.buckets {
+		if feePerKB < bucket {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// processBlock records the number of blocks the transactions in pool took to
+// be included in the block, the transactions not accepted to the pool before
+// are not tracked.
+func (e *FeeEstimator) processBlock(block *Block, pool *TxPool) {
+	e.Lock()
+	defer e.Unlock()
+
+	for b := range e.buckets {
+		e.total[b] *= feeEstimateDecay
+		for t := range e.confirmed[b] {
+			e.confirmed[b][t] *= feeEstimateDecay
+		}
+	}
+
+	for _, txn := range block.Transactions {
+		txHash := txn.Hash()
+		// the fee is computed when the transaction is accepted to the pool
+		poolTx := pool.GetTransaction(txHash)
+		if poolTx == nil {
+			continue
+		}
+		_, height, ok := pool.GetTxAcceptance(txHash)
+		if !ok || height >= block.Header.Height {
+			continue
+		}
+
+		b := e.bucketIndex(poolTx.FeePerKB)
+		e.total[b]++
+		for t := int(block.Header.Height-height) - 1; t < MaxFeeEstimateBlocks; t++ {
+			e.confirmed[b][t]++
+		}
+	}
+}
+
+// estimateFee returns the lowest fee per KB that the transactions paying it
+// are included within the target blocks at the success rate, waiting[b] is
+// the number of the transactions in bucket b waiting for more than the target
+// blocks in pool.
+func (e *FeeEstimator) estimateFee(target uint32, waiting []float64) (Fixed64, error) {
+	if target < 1 || target > MaxFeeEstimateBlocks {
+		return 0, fmt.Errorf("target blocks should be in 1-%d", MaxFeeEstimateBlocks)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	// the buckets are grouped from the highest one until there are enough
+	// transactions in the group, the estimate is lowered to the group if
+	// enough of them are included within the target blocks
+	var estimate Fixed64
+	var confirmed, total float64
+	found := false
+	for b := len(e.buckets) - 1; b >= 0; b-- {
+		confirmed += e.confirmed[b][target-1]
+		total += e.total[b] + waiting[b]
+		if total < minFeeEstimateData {
+			continue
+		}
+		if confirmed/total < feeEstimateSuccess {
+			break
+		}
+		estimate = e.buckets[b]
+		found = true
+		confirmed, total = 0, 0
+	}
+	if !found {
+		return 0, errors.New("insufficient data to estimate fee")
+	}
+	return estimate, nil
+}
+
+// EstimateFee returns the fee per KB a transaction pays to be included within
+// the target blocks, it is not lower than the min fee of the pool.
+func (pool *TxPool) EstimateFee(target uint32) (Fixed64, error) {
+	bestHeight := DefaultLedger.Blockchain.GetBestHeight()
+	waiting := make([]float64, len(pool.feeEstimator.buckets))
+	pool.RLock()
+	for hash, entry := range pool.txnEntries {
+		if entry.height < bestHeight && bestHeight-entry.height > target {
+			waiting[pool.feeEstimator.bucketIndex(pool.txnList[hash].FeePerKB)]++
+		}
+	}
+	pool.RUnlock()
+
+	estimate, err := pool.feeEstimator.estimateFee(target, waiting)
+	if err != nil {
+		return 0, err
+	}
+	if minFee := pool.GetMinFeePerKB(); estimate < minFee {
+		estimate = minFee
+	}
+	return estimate, nil
+}
@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeEstimator_EstimateFee(t *testing.T) {
+	pool := newTestTxPool()
+	estimator := pool.feeEstimator
+	waiting := make([]float64, len(estimator.buckets))
+
+	_, err := estimator.estimateFee(0, waiting)
+	assert.Error(t, err)
+	_, err = estimator.estimateFee(MaxFeeEstimateBlocks+1, waiting)
+	assert.Error(t, err)
+	// no transaction is tracked
+	_, err = estimator.estimateFee(1, waiting)
+	assert.Error(t, err)
+
+	// the high fee transactions are included in the next block, and the low
+	// fee ones take 5 blocks
+	outPoint := uint32(0)
+	newTx := func(feePerKB common.Fixed64, height uint32) *core.Transaction {
+		outPoint++
+		txn := newSpendTransaction(core.OutPoint{TxID: common.Uint256{byte(outPoint), byte(outPoint >> 8)}})
+		txn.FeePerKB = feePerKB
+		pool.addToTxList(txn)
+		pool.txnEntries[txn.Hash()].height = height
+		return txn
+	}
+	for height := uint32(1); height <= 20; height++ {
+		block := &core.Block{Header: core.Header{Height: height}}
+		for i := 0; i < 5; i++ {
+			block.Transactions = append(block.Transactions, newTx(10000, height-1))
+			if height > 5 {
+				block.Transactions = append(block.Transactions, newTx(1000, height-5))
+			}
+		}
+		estimator.processBlock(block, pool)
+		for _, txn := range block.Transactions {
+			pool.delFromTxList(txn.Hash())
+		}
+	}
+
+	high := estimator.buckets[estimator.bucketIndex(10000)]
+	low := estimator.buckets[estimator.bucketIndex(1000)]
+	fee, err := estimator.estimateFee(1, waiting)
+	if assert.NoError(t, err) {
+		assert.Equal(t, high, fee)
+	}
+	fee, err = estimator.estimateFee(5, waiting)
+	if assert.NoError(t, err) {
+		assert.Equal(t, low, fee)
+	}
+
+	// the low fee transactions waiting in pool lower the success rate
+	waiting[estimator.bucketIndex(1000)] = 100
+	fee, err = estimator.estimateFee(5, waiting)
+	if assert.NoError(t, err) {
+		assert.Equal(t, high, fee)
+	}
+}
@@ -0,0 +1,220 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// The HTLC test vectors, the private keys of the recipient and the sender are
+// the SHA-256 hashes of "recipient" and "sender", the preimage is
+// "atomic swap secret", the lock height is 1000, and the signatures are of
+// "htlc test data".
+const (
+	htlcRecipient = "039feeeda903d83d01f8859d5c1171d72ff9ce5ff221b480a46068d97e3d40de9e"
+	htlcSender    = "0228f111b0ce44c4be7581b07bca9319f1734af9aacc2d3321d698a561127dc662"
+	htlcHash      = "67db18729fdf52043d1e01fe445c537277af68c39e2ef42826b63dea50b57f8d"
+	htlcCode      = "21039feeeda903d83d01f8859d5c1171d72ff9ce5ff221b480a46068d97e3d40de9e" +
+		"210228f111b0ce44c4be7581b07bca9319f1734af9aacc2d3321d698a561127dc662" +
+		"2067db18729fdf52043d1e01fe445c537277af68c39e2ef42826b63dea50b57f8d" +
+		"04e8030000b0"
+	htlcAddress = "CVVRnad45St4YM4fd6wJzad66ZcQgo3stH"
+
+	htlcRecipientSignature = "a17b16ba594e09715cc6e5fd7e9c5610074c7e656b1058630dd29bc10193e854" +
+		"1f7c8f49f7ad14328e2f1b0b04db2933ff748f3f590d04b2983b434efca9ff32"
+	htlcSenderSignature = "4599428f0d8730ac8aeff10bada1efd241cbc0596e18a5720fcbdf8a00be1b4a" +
+		"cba8200d7e8ea33e92734c37fa072942004825797c80d4baa80ab1f79d1a5240"
+)
+
+var (
+	htlcPreimage = []byte("atomic swap secret")
+	htlcData     = []byte("htlc test data")
+)
+
+func hexToBytes(t *testing.T, str string) []byte {
+	bytes, err := common.HexStringToBytes(str)
+	if err != nil {
+		t.Fatalf("Decode hex string failed, error %s", err.Error())
+	}
+	return bytes
+}
+
+// htlcParameter returns the HTLC program parameter of the signature, followed
+// by the preimage if it is not nil.
+func htlcParameter(t *testing.T, signature string, preimage []byte) []byte {
+	parameter := append([]byte{crypto.SignatureLength}, hexToBytes(t, signature)...)
+	if preimage != nil {
+		parameter = append(parameter, byte(len(preimage)))
+		parameter = append(parameter, preimage...)
+	}
+	return parameter
+}
+
+func TestHTLCScript(t *testing.T) {
+	recipient, err := crypto.DecodePoint(hexToBytes(t, htlcRecipient))
+	assert.NoError(t, err)
+	sender, err := crypto.DecodePoint(hexToBytes(t, htlcSender))
+	assert.NoError(t, err)
+	script := &core.HTLCScript{
+		Recipient:  recipient,
+		Sender:     sender,
+		Hash:       sha256.Sum256(htlcPreimage),
+		LockHeight: 1000,
+	}
+	assert.Equal(t, htlcHash, common.BytesToHexString(script.Hash[:]))
+
+	code, err := core.CreateHTLCRedeemScript(script)
+	assert.NoError(t, err)
+	assert.Equal(t, htlcCode, common.BytesToHexString(code))
+	assert.True(t, core.IsHTLCCode(code))
+
+	parsed, err := core.ParseHTLCScript(code)
+	if assert.NoError(t, err) {
+		assert.True(t, crypto.Equal(recipient, parsed.Recipient))
+		assert.True(t, crypto.Equal(sender, parsed.Sender))
+		assert.Equal(t, script.Hash, parsed.Hash)
+		assert.Equal(t, uint32(1000), parsed.LockHeight)
+	}
+
+	// the address of the HTLC program
+	programHash, err := toProgramHash(code)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(core.PrefixHTLC), programHash[0])
+	address, err := programHash.ToAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, htlcAddress, address)
+	decoded, err := common.Uint168FromAddress(htlcAddress)
+	if assert.NoError(t, err) {
+		assert.Equal(t, *programHash, *decoded)
+	}
+
+	// the content lengths are checked
+	code[0] = 32
+	_, err = core.ParseHTLCScript(code)
+	assert.Error(t, err)
+	_, err = core.ParseHTLCScript(code[1:])
+	assert.Error(t, err)
+}
+
+func TestParseHTLCParameter(t *testing.T) {
+	parameter := htlcParameter(t, htlcSenderSignature, nil)
+	signature, preimage, err := core.ParseHTLCParameter(parameter)
+	assert.NoError(t, err)
+	assert.Equal(t, parameter, signature)
+	assert.Nil(t, preimage)
+
+	parameter = htlcParameter(t, htlcRecipientSignature, htlcPreimage)
+	signature, preimage, err = core.ParseHTLCParameter(parameter)
+	assert.NoError(t, err)
+	assert.Equal(t, parameter[:crypto.SignatureScriptLength], signature)
+	assert.Equal(t, htlcPreimage, preimage)
+
+	// the length of the preimage does not match
+	parameter[crypto.SignatureScriptLength]++
+	_, _, err = core.ParseHTLCParameter(parameter)
+	assert.Error(t, err)
+	_, _, err = core.ParseHTLCParameter(parameter[:crypto.SignatureScriptLength+1])
+	assert.Error(t, err)
+	_, _, err = core.ParseHTLCParameter(parameter[:crypto.SignatureScriptLength-1])
+	assert.Error(t, err)
+}
+
+func TestRunPrograms_HTLC(t *testing.T) {
+	cache := DefaultSigCache
+	defer func() { DefaultSigCache = cache }()
+	DefaultSigCache = NewSigCache(100)
+	htlcHeight := config.Parameters.ChainParam.HTLCHeight
+	defer func() { config.Parameters.ChainParam.HTLCHeight = htlcHeight }()
+	config.Parameters.ChainParam.HTLCHeight = 100
+
+	code := hexToBytes(t, htlcCode)
+	programHash, err := common.Uint168FromAddress(htlcAddress)
+	assert.NoError(t, err)
+	hashes := []common.Uint168{*programHash}
+	runAt := func(height uint32, parameter []byte) error {
+		return RunPrograms(htlcData, hashes, []*core.Program{{Code: code, Parameter: parameter}}, height)
+	}
+	run := func(parameter []byte) error {
+		return runAt(100, parameter)
+	}
+
+	// the recipient spends it with the preimage
+	assert.NoError(t, run(htlcParameter(t, htlcRecipientSignature, htlcPreimage)))
+	assert.Error(t, run(htlcParameter(t, htlcRecipientSignature, []byte("wrong secret"))))
+	assert.Error(t, run(htlcParameter(t, htlcSenderSignature, htlcPreimage)))
+
+	// the sender spends it without the preimage
+	assert.NoError(t, run(htlcParameter(t, htlcSenderSignature, nil)))
+	assert.Error(t, run(htlcParameter(t, htlcRecipientSignature, nil)))
+
+	// the signed data is changed
+	parameter := htlcParameter(t, htlcSenderSignature, nil)
+	assert.Error(t, RunPrograms([]byte("other data"), hashes,
+		[]*core.Program{{Code: code, Parameter: parameter}}, 100))
+
+	// the HTLC programs are rejected before the activation, even if the
+	// signatures are cached
+	assert.EqualError(t, runAt(99, parameter), "HTLC program is not active")
+	assert.NoError(t, runAt(math.MaxUint32, parameter))
+}
+
+func TestCheckOutputProgramHash_HTLC(t *testing.T) {
+	ledger := DefaultLedger
+	defer func() { DefaultLedger = ledger }()
+	htlcHeight := config.Parameters.ChainParam.HTLCHeight
+	defer func() { config.Parameters.ChainParam.HTLCHeight = htlcHeight }()
+	config.Parameters.ChainParam.HTLCHeight = 100
+
+	programHash, err := common.Uint168FromAddress(htlcAddress)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, CheckOutputProgramHash(*programHash, 0))
+	assert.False(t, CheckOutputProgramHash(*programHash, 99))
+	assert.True(t, CheckOutputProgramHash(*programHash, 100))
+
+	// the output paying to an HTLC address is checked at the block height
+	store, _ := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, Init(store)) {
+		return
+	}
+	tx := newSpendTransaction(core.OutPoint{TxID: common.Uint256{1}})
+	tx.Outputs = []*core.Output{{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: *programHash}}
+	assert.EqualError(t, CheckTransactionOutput(core.CheckTxOut, tx, 99), "output address is invalid")
+	assert.NoError(t, CheckTransactionOutput(core.CheckTxOut, tx, 100))
+}
+
+func TestCheckHTLCLock(t *testing.T) {
+	code := hexToBytes(t, htlcCode)
+	programHash, err := common.Uint168FromAddress(htlcAddress)
+	assert.NoError(t, err)
+
+	tx := newSpendTransaction(core.OutPoint{TxID: common.Uint256{1}})
+	input := tx.Inputs[0]
+	references := map[*core.Input]*core.Output{input: {ProgramHash: *programHash}}
+	program := &core.Program{Code: code, Parameter: htlcParameter(t, htlcRecipientSignature, htlcPreimage)}
+	tx.Programs = []*core.Program{program}
+
+	// the recipient is not locked
+	input.Sequence = math.MaxUint32
+	assert.NoError(t, CheckHTLCLock(tx, references))
+
+	// the sender is locked until the lock height
+	program.Parameter = htlcParameter(t, htlcSenderSignature, nil)
+	tx.LockTime = 1000
+	assert.EqualError(t, CheckHTLCLock(tx, references), "Invalid input sequence of HTLC output")
+	input.Sequence = math.MaxUint32 - 1
+	assert.NoError(t, CheckHTLCLock(tx, references))
+	tx.LockTime = 999
+	assert.EqualError(t, CheckHTLCLock(tx, references), "HTLC output locked")
+	tx.LockTime = LockTimeThreshold + 1000
+	assert.EqualError(t, CheckHTLCLock(tx, references), "HTLC output locked")
+}
@@ -1,6 +1,8 @@
 package blockchain
 
 import (
+	"io"
+
 	. "github.com/elastos/Elastos.ELA/core"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
@@ -37,10 +39,21 @@ type IChainStore interface {
 	ContainsUnspent(txid Uint256, index uint16) (bool, error)
 	GetUnspentFromProgramHash(programHash Uint168, assetid Uint256) ([]*UTXO, error)
 	GetUnspentsFromProgramHash(programHash Uint168) (map[Uint256][]*UTXO, error)
+	GetAddressTransactions(programHash Uint168, skip, limit uint32) ([]*AddressTx, error)
+	GetSpentBy(txId Uint256, index uint16) (*SpentBy, error)
 	GetAssets() map[Uint256]*Asset
 
+	GetUTXOSetHash() (Uint256, error)
+	CreateTipUTXOSnapshot(w io.Writer, magic uint32) (uint32, Uint256, error)
+	RestoreUTXOSnapshot(r io.Reader, magic uint32) (uint32, Uint256, error)
+
+	CheckDB(scratchDir string) ([]string, error)
+	Reindex() error
+
 	IsTxHashDuplicate(txhash Uint256) bool
 	IsSidechainTxHashDuplicate(sidechainTxHash Uint256) bool
 	IsBlockInStore(hash Uint256) bool
+	IsBlockPruned(hash Uint256) bool
+	GetPrunedHeight() uint32
 	Close()
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
@@ -39,6 +40,21 @@ func NewLevelDB(file string) (*LevelDB, error) {
 	}, nil
 }
 
+// NewMemLevelDB returns a LevelDB which keeps all data in memory, it has the
+// same iteration order and batch semantics with the file based one, and is
+// used for unit tests and throwaway chains. All data is lost after Close.
+func NewMemLevelDB() (*LevelDB, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelDB{
+		db:    db,
+		batch: nil,
+	}, nil
+}
+
 func (ldb *LevelDB) Put(key []byte, value []byte) error {
 	return ldb.db.Put(key, value, nil)
 }
@@ -75,3 +91,29 @@ func (ldb *LevelDB) NewIterator(prefix []byte) IIterator {
 	iter := ldb.db.NewIterator(util.BytesPrefix(prefix), nil)
 	return &Iterator{iter: iter}
 }
+
+func (ldb *LevelDB) NewSnapshot() (IStoreSnapshot, error) {
+	snapshot, err := ldb.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBSnapshot{snapshot: snapshot}, nil
+}
+
+// LevelDBSnapshot is a snapshot of a LevelDB.
+type LevelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (s *LevelDBSnapshot) Get(key []byte) ([]byte, error) {
+	return s.snapshot.Get(key, nil)
+}
+
+func (s *LevelDBSnapshot) NewIterator(prefix []byte) IIterator {
+	iter := s.snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+	return &Iterator{iter: iter}
+}
+
+func (s *LevelDBSnapshot) Release() {
+	s.snapshot.Release()
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemLevelDB(t *testing.T) {
+	db, err := NewMemLevelDB()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	// batch writes are not visible until committed
+	db.NewBatch()
+	db.BatchPut([]byte{0x01, 0x03}, []byte("c"))
+	db.BatchPut([]byte{0x01, 0x01}, []byte("a"))
+	db.BatchPut([]byte{0x02, 0x01}, []byte("x"))
+	db.BatchPut([]byte{0x01, 0x02}, []byte("b"))
+	_, err = db.Get([]byte{0x01, 0x01})
+	assert.Error(t, err)
+
+	assert.NoError(t, db.BatchCommit())
+	value, err := db.Get([]byte{0x01, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), value)
+
+	// prefix iteration is in key order and does not cross the prefix
+	var values [][]byte
+	iter := db.NewIterator([]byte{0x01})
+	for iter.Next() {
+		values = append(values, append([]byte{}, iter.Value()...))
+	}
+	iter.Release()
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, values)
+
+	// delete in batch
+	db.NewBatch()
+	db.BatchDelete([]byte{0x01, 0x02})
+	assert.NoError(t, db.BatchCommit())
+	_, err = db.Get([]byte{0x01, 0x02})
+	assert.Error(t, err)
+
+	iter = db.NewIterator([]byte{0x01})
+	assert.True(t, iter.Last())
+	assert.True(t, bytes.Equal([]byte{0x01, 0x03}, iter.Key()))
+	assert.True(t, iter.Prev())
+	assert.True(t, bytes.Equal([]byte{0x01, 0x01}, iter.Key()))
+	assert.False(t, iter.Prev())
+	iter.Release()
+}
+
+func TestMemLevelDB_Snapshot(t *testing.T) {
+	db, err := NewMemLevelDB()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	assert.NoError(t, db.Put([]byte{0x01, 0x01}, []byte("a")))
+
+	snapshot, err := db.NewSnapshot()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer snapshot.Release()
+
+	// the batches committed after the snapshot are not seen
+	db.NewBatch()
+	db.BatchPut([]byte{0x01, 0x01}, []byte("b"))
+	db.BatchPut([]byte{0x01, 0x02}, []byte("c"))
+	assert.NoError(t, db.BatchCommit())
+
+	value, err := snapshot.Get([]byte{0x01, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), value)
+	_, err = snapshot.Get([]byte{0x01, 0x02})
+	assert.Error(t, err)
+
+	var keys int
+	iter := snapshot.NewIterator([]byte{0x01})
+	for iter.Next() {
+		keys++
+	}
+	iter.Release()
+	assert.Equal(t, 1, keys)
+}
@@ -0,0 +1,171 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// CurrentStoreVersion is the version of the store layout written by this
+// software, a newly created store is marked with this version directly.
+const CurrentStoreVersion = 0x03
+
+// MigrationBatchBlocks is the number of blocks processed in one batch by a
+// block based migration, the progress is saved along with each batch.
+const MigrationBatchBlocks = 1000
+
+// migration upgrades the store from version-1 to version.
+type migration struct {
+	version byte
+	name    string
+	migrate func(c *ChainStore, version byte) error
+}
+
+// migrations must be kept in version order, every change of the store layout
+// should add a new entry here and bump CurrentStoreVersion.
+var migrations = []migration{
+	{version: 0x02, name: "build address transaction index", migrate: migrateAddressTxIndex},
+	{version: 0x03, name: "build spent-by index", migrate: migrateSpentByIndex},
+}
+
+func (c *ChainStore) getStoreVersion() byte {
+	version, err := c.Get([]byte{byte(CFG_Version)})
+	if err != nil || len(version) == 0 {
+		return 0x00
+	}
+	return version[0]
+}
+
+// migrate runs the migrations newer than the store version in order, the
+// store version is updated after each migration, so an interrupted upgrade
+// continues from the step it was stopped at.
+func (c *ChainStore) migrate() error {
+	version := c.getStoreVersion()
+	if version > CurrentStoreVersion {
+		return fmt.Errorf("store version %d is newer than supported version %d", version, CurrentStoreVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		log.Infof("[Migration] upgrading store from version %d to %d: %s", version, m.version, m.name)
+		if err := m.migrate(c, m.version); err != nil {
+			return fmt.Errorf("migrate store to version %d failed: %s", m.version, err)
+		}
+
+		c.NewBatch()
+		c.BatchPut([]byte{byte(CFG_Version)}, []byte{m.version})
+		c.BatchDelete([]byte{byte(CFG_Migration)})
+		if err := c.BatchCommit(); err != nil {
+			return err
+		}
+		version = m.version
+		log.Infof("[Migration] store upgraded to version %d", version)
+	}
+
+	return nil
+}
+
+// getMigrationProgress returns the next block height to be processed by the
+// migration to the given version.
+func (c *ChainStore) getMigrationProgress(version byte) uint32 {
+	data, err := c.Get([]byte{byte(CFG_Migration)})
+	if err != nil || len(data) != 5 || data[0] != version {
+		return 0
+	}
+
+	height, err := ReadUint32(bytes.NewReader(data[1:]))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
+func (c *ChainStore) batchPutMigrationProgress(version byte, height uint32) error {
+	value := new(bytes.Buffer)
+	value.WriteByte(version)
+	if err := WriteUint32(value, height); err != nil {
+		return err
+	}
+	c.BatchPut([]byte{byte(CFG_Migration)}, value.Bytes())
+	return nil
+}
+
+// migrateBlocks calls handler with every block in the store in height
+// order, the batch is committed with the progress every MigrationBatchBlocks
+// blocks, handler should only write through the batch.
+func (c *ChainStore) migrateBlocks(version byte, handler func(b *Block) error) error {
+	if _, err := c.Get([]byte{byte(SYS_CurrentBlock)}); err != nil {
+		// nothing persisted yet
+		return nil
+	}
+	_, endHeight, err := c.getStoredCurrentBlock()
+	if err != nil {
+		return err
+	}
+
+	start := c.getMigrationProgress(version)
+	if start > 0 {
+		log.Infof("[Migration] resume from block %d", start)
+	}
+
+	c.NewBatch()
+	for height := start; height <= endHeight; height++ {
+		hash, err := c.GetBlockHash(height)
+		if err != nil {
+			return err
+		}
+		block, err := c.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if err := handler(block); err != nil {
+			return err
+		}
+
+		if (height+1)%MigrationBatchBlocks == 0 || height == endHeight {
+			if err := c.batchPutMigrationProgress(version, height+1); err != nil {
+				return err
+			}
+			if err := c.BatchCommit(); err != nil {
+				return err
+			}
+			log.Infof("[Migration] processed %d/%d blocks", height+1, endHeight+1)
+			c.NewBatch()
+		}
+	}
+
+	return nil
+}
+
+func migrateAddressTxIndex(c *ChainStore, version byte) error {
+	return c.migrateBlocks(version, func(b *Block) error {
+		spentOutputs, err := c.getBlockSpentOutputs(b)
+		if err != nil {
+			return err
+		}
+		for _, txn := range b.Transactions {
+			if err := c.PersistAddressTransaction(txn, b.Header.Height, spentOutputs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func migrateSpentByIndex(c *ChainStore, version byte) error {
+	return c.migrateBlocks(version, func(b *Block) error {
+		for _, txn := range b.Transactions {
+			if err := c.PersistSpentBy(txn, b.Header.Height); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
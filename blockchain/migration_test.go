@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainStore_Migrate(t *testing.T) {
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	// simulate a store of version 0x01 which has no address transaction index
+	store.NewBatch()
+	iter := store.NewIterator([]byte{byte(IX_Address_Tx)})
+	for iter.Next() {
+		store.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	store.BatchPut([]byte{byte(CFG_Version)}, []byte{0x01})
+	assert.NoError(t, store.BatchCommit())
+
+	// an interrupted migration continues from the saved progress, so the
+	// genesis block is skipped here
+	store.NewBatch()
+	assert.NoError(t, store.batchPutMigrationProgress(0x02, 1))
+	assert.NoError(t, store.BatchCommit())
+	assert.Equal(t, uint32(0), store.getMigrationProgress(0x03))
+	if !assert.NoError(t, store.migrate()) {
+		return
+	}
+	assert.Equal(t, byte(CurrentStoreVersion), store.getStoreVersion())
+	_, err := store.Get([]byte{byte(CFG_Migration)})
+	assert.Error(t, err)
+	txs, err := store.GetAddressTransactions(FoundationAddress, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+
+	// run the migration from the beginning
+	assert.NoError(t, store.Put([]byte{byte(CFG_Version)}, []byte{0x01}))
+	if !assert.NoError(t, store.migrate()) {
+		return
+	}
+	assert.Equal(t, byte(CurrentStoreVersion), store.getStoreVersion())
+
+	txs, err = store.GetAddressTransactions(FoundationAddress, 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, txs, 1) {
+		assert.Equal(t, genesis.Transactions[0].Hash(), txs[0].TxId)
+		assert.Equal(t, uint32(0), txs[0].Height)
+	}
+
+	// a store newer than this software can not be opened
+	assert.NoError(t, store.Put([]byte{byte(CFG_Version)}, []byte{CurrentStoreVersion + 1}))
+	assert.Error(t, store.migrate())
+}
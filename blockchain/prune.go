@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// MinPruneBlocks is the min number of recent blocks kept by a pruned node,
+// the chain can not be reorganized below them.
+const MinPruneBlocks = 720
+
+// A pruned node deletes the transactions of the blocks below the pruned
+// height once they are no longer needed, the block hashes, headers, UTXO
+// indexes and asset info are kept. A transaction is deleted only if all of
+// its outputs are spent by the pruned blocks, so the blocks above the pruned
+// height can still be rolled back. The genesis block is never pruned.
+
+// key: SYS_PrunedHeight
+// value: the height below which all blocks are pruned
+func (c *ChainStore) GetPrunedHeight() uint32 {
+	data, err := c.Get([]byte{byte(SYS_PrunedHeight)})
+	if err != nil {
+		return 0
+	}
+	height, err := ReadUint32(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
+func (c *ChainStore) batchPutPrunedHeight(height uint32) error {
+	value := new(bytes.Buffer)
+	if err := WriteUint32(value, height); err != nil {
+		return err
+	}
+	c.BatchPut([]byte{byte(SYS_PrunedHeight)}, value.Bytes())
+	return nil
+}
+
+// IsBlockPruned returns if the full data of the block is pruned, the genesis
+// block is never pruned.
+func (c *ChainStore) IsBlockPruned(hash Uint256) bool {
+	header, err := c.GetHeader(hash)
+	if err != nil {
+		return false
+	}
+	return header.Height > 0 && header.Height < c.GetPrunedHeight()
+}
+
+// prunedHeightAt returns the pruned height when the best block is at the
+// given height, zero if the node is not pruned.
+func (c *ChainStore) prunedHeightAt(height uint32) uint32 {
+	if c.pruneBlocks == 0 || height < c.pruneBlocks {
+		return 0
+	}
+	return height - c.pruneBlocks + 1
+}
+
+// canPruneTransaction returns if the transaction is below the pruned height
+// and all of its outputs are spent below the pruned height.
+func (c *ChainStore) canPruneTransaction(txId Uint256, prunedHeight uint32) bool {
+	txn, height, err := c.GetTransaction(txId)
+	if err != nil {
+		// pruned already
+		return false
+	}
+	if height == 0 || height >= prunedHeight || txn.TxType == RegisterAsset {
+		return false
+	}
+	if _, err := c.Get(append([]byte{byte(IX_Unspent)}, txId.Bytes()...)); err == nil {
+		return false
+	}
+	for index := range txn.Outputs {
+		spentBy, err := c.GetSpentBy(txId, uint16(index))
+		if err != nil || spentBy.Height >= prunedHeight {
+			return false
+		}
+	}
+	return true
+}
+
+// batchPruneBlock deletes the transactions which are no longer needed once
+// the block at the given height is pruned, they are the transactions of the
+// block and the transactions it spent from.
+func (c *ChainStore) batchPruneBlock(height uint32) error {
+	hash, err := c.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+	data, err := c.Get(append([]byte{byte(DATA_Header)}, hash.Bytes()...))
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(data)
+	// first 8 bytes is sys_fee
+	if _, err := ReadUint64(r); err != nil {
+		return err
+	}
+	trimmed := new(Block)
+	if err := trimmed.FromTrimmedData(r); err != nil {
+		return err
+	}
+
+	candidates := make(map[Uint256]struct{})
+	for _, txn := range trimmed.Transactions {
+		txHash := txn.Hash()
+		candidates[txHash] = struct{}{}
+
+		fullTxn, _, err := c.GetTransaction(txHash)
+		if err != nil || fullTxn.IsCoinBaseTx() {
+			continue
+		}
+		for _, input := range fullTxn.Inputs {
+			candidates[input.Previous.TxID] = struct{}{}
+		}
+	}
+
+	for txId := range candidates {
+		if c.canPruneTransaction(txId, height+1) {
+			c.BatchDelete(append([]byte{byte(DATA_Transaction)}, txId.Bytes()...))
+		}
+	}
+	c.BatchDelete(getBlockUndoKey(hash))
+	return nil
+}
+
+// PruneBlocks prunes the blocks which are out of the kept blocks once the
+// given block is persisted.
+func (c *ChainStore) PruneBlocks(b *Block) error {
+	target := c.prunedHeightAt(b.Header.Height)
+	from := c.GetPrunedHeight()
+	if from == 0 {
+		from = 1
+	}
+	if target <= from {
+		return nil
+	}
+
+	for height := from; height < target; height++ {
+		if err := c.batchPruneBlock(height); err != nil {
+			return err
+		}
+	}
+	return c.batchPutPrunedHeight(target)
+}
+
+// pruneStore prunes the blocks out of the kept blocks at start up, which is
+// needed if pruning is enabled on a store which is not pruned, the progress
+// is committed every MigrationBatchBlocks blocks.
+func (c *ChainStore) pruneStore() error {
+	_, bestHeight, err := c.getStoredCurrentBlock()
+	if err != nil {
+		return err
+	}
+	target := c.prunedHeightAt(bestHeight)
+	from := c.GetPrunedHeight()
+	if from == 0 {
+		from = 1
+	}
+	if target <= from {
+		return nil
+	}
+
+	log.Infof("[Prune] pruning blocks from height %d to %d", from, target-1)
+	c.NewBatch()
+	for height := from; height < target; height++ {
+		if err := c.batchPruneBlock(height); err != nil {
+			return err
+		}
+		if (height+1)%MigrationBatchBlocks == 0 || height+1 == target {
+			if err := c.batchPutPrunedHeight(height + 1); err != nil {
+				return err
+			}
+			if err := c.BatchCommit(); err != nil {
+				return err
+			}
+			c.NewBatch()
+		}
+	}
+	return nil
+}
+
+// checkPruneBlocks checks the number of blocks kept by a pruned node.
+func checkPruneBlocks(pruneBlocks uint32) error {
+	if pruneBlocks != 0 && pruneBlocks < MinPruneBlocks {
+		return fmt.Errorf("a pruned node must keep at least %d blocks, not %d", MinPruneBlocks, pruneBlocks)
+	}
+	return nil
+}
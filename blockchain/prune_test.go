@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainStore_PruneBlocks(t *testing.T) {
+	store, genesis, _ := newRecoveryTestStore(t)
+	defer store.Close()
+	store.pruneBlocks = 2
+
+	assetID := genesis.Transactions[1].Hash()
+	newBlock := func(prev *core.Block, txs ...*core.Transaction) *core.Block {
+		height := prev.Header.Height + 1
+		coinbase := NewCoinBaseTransaction(&core.PayloadCoinBase{}, height)
+		coinbase.Outputs = []*core.Output{{AssetID: assetID, Value: 1}}
+		return &core.Block{
+			Header:       core.Header{Height: height, Previous: prev.Hash()},
+			Transactions: append([]*core.Transaction{coinbase}, txs...),
+		}
+	}
+	spend := func(txn *core.Transaction) *core.Transaction {
+		spender := newSpendTransaction(core.OutPoint{TxID: txn.Hash(), Index: 0})
+		spender.Outputs = []*core.Output{{AssetID: assetID, Value: txn.Outputs[0].Value}}
+		return spender
+	}
+
+	// s2 spends the genesis coinbase, s3 spends s2 and s4 spends s3
+	s2 := spend(genesis.Transactions[0])
+	s3 := spend(s2)
+	s4 := spend(s3)
+	blocks := []*core.Block{genesis}
+	blocks = append(blocks, newBlock(blocks[0]))
+	blocks = append(blocks, newBlock(blocks[1], s2))
+	blocks = append(blocks, newBlock(blocks[2], s3))
+	blocks = append(blocks, newBlock(blocks[3], s4))
+	blocks = append(blocks, newBlock(blocks[4]))
+	for _, block := range blocks {
+		if !assert.NoError(t, store.persist(block)) {
+			return
+		}
+	}
+
+	// blocks 1 to 3 are pruned, blocks 4 and 5 are kept
+	assert.Equal(t, uint32(4), store.GetPrunedHeight())
+	for _, block := range blocks[1:4] {
+		assert.True(t, store.IsBlockPruned(block.Hash()))
+		_, err := store.GetHeader(block.Hash())
+		assert.NoError(t, err)
+	}
+	_, err := store.GetBlock(blocks[2].Hash())
+	assert.Error(t, err)
+	for _, block := range []*core.Block{blocks[0], blocks[4], blocks[5]} {
+		assert.False(t, store.IsBlockPruned(block.Hash()))
+		_, err := store.GetBlock(block.Hash())
+		assert.NoError(t, err)
+	}
+
+	hasTransaction := func(txn *core.Transaction) bool {
+		_, _, err := store.GetTransaction(txn.Hash())
+		return err == nil
+	}
+	// the unspent coinbases are kept
+	for _, block := range blocks[1:4] {
+		assert.True(t, hasTransaction(block.Transactions[0]))
+	}
+	// s2 is spent by a pruned block, s3 is spent by a kept block
+	assert.False(t, hasTransaction(s2))
+	assert.True(t, hasTransaction(s3))
+	_, err = store.GetUnspent(s4.Hash(), 0)
+	assert.NoError(t, err)
+
+	// the kept blocks can still be rolled back
+	entries := dumpStore(store.IStore)
+	block := blocks[len(blocks)-1]
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	if !assert.NoError(t, store.commitBlockSteps(blocks[4], blockRollbackSteps)) {
+		return
+	}
+	_, err = store.GetUnspent(s3.Hash(), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, store.persist(blocks[4]))
+	assert.NoError(t, store.persist(block))
+	assert.Equal(t, entries, dumpStore(store.IStore))
+
+	// a pruned store can not be checked
+	_, err = checkTestDB(t, store)
+	assert.Error(t, err)
+}
+
+func TestChainStore_PruneStore(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	next := &core.Block{
+		Header:       core.Header{Height: 2, Previous: block.Hash()},
+		Transactions: []*core.Transaction{NewCoinBaseTransaction(&core.PayloadCoinBase{}, 2)},
+	}
+	for _, b := range []*core.Block{genesis, block, next} {
+		if !assert.NoError(t, store.persist(b)) {
+			return
+		}
+	}
+	assert.NoError(t, store.pruneStore())
+	assert.Equal(t, uint32(0), store.GetPrunedHeight())
+
+	// pruning is enabled on a store which is not pruned
+	store.pruneBlocks = 1
+	assert.NoError(t, store.pruneStore())
+	assert.Equal(t, uint32(2), store.GetPrunedHeight())
+	assert.True(t, store.IsBlockPruned(block.Hash()))
+	assert.False(t, store.IsBlockPruned(next.Hash()))
+
+	assert.Error(t, checkPruneBlocks(MinPruneBlocks-1))
+	assert.NoError(t, checkPruneBlocks(MinPruneBlocks))
+	assert.NoError(t, checkPruneBlocks(0))
+}
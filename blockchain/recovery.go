@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"bytes"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// A block is committed in one batch by persist and rollback, so LevelDB
+// never leaves it partially written. The recovery step at start up still
+// repairs a partially written block, which may be left by a crash of an
+// older version, a store without atomic batch or a damaged disk.
+
+// isBlockComplete checks the block at the given height and all of its
+// transactions are in store.
+func (c *ChainStore) isBlockComplete(height uint32) bool {
+	hash, err := c.GetBlockHash(height)
+	if err != nil {
+		return false
+	}
+	block, err := c.GetBlock(hash)
+	if err != nil || block.Header.Height != height {
+		return false
+	}
+	for _, txn := range block.Transactions {
+		if _, txHeight, err := c.GetTransaction(txn.Hash()); err != nil || txHeight != height {
+			return false
+		}
+	}
+	return true
+}
+
+// removeBlocksAbove deletes the block data above the given height, which is
+// left by a block written without updating the current block. It returns
+// the number of blocks removed.
+func (c *ChainStore) removeBlocksAbove(height uint32) (uint32, error) {
+	var removed uint32
+	c.NewBatch()
+	for h := height + 1; ; h++ {
+		key := new(bytes.Buffer)
+		key.WriteByte(byte(DATA_BlockHash))
+		if err := WriteUint32(key, h); err != nil {
+			return 0, err
+		}
+		hash, err := c.GetBlockHash(h)
+		if err != nil {
+			break
+		}
+		c.BatchDelete(key.Bytes())
+
+		headerKey := append([]byte{byte(DATA_Header)}, hash.Bytes()...)
+		if data, err := c.Get(headerKey); err == nil {
+			block := new(Block)
+			r := bytes.NewReader(data)
+			// first 8 bytes is sys_fee
+			if _, err := ReadUint64(r); err == nil && block.FromTrimmedData(r) == nil {
+				for _, txn := range block.Transactions {
+					// only the transactions written by this block
+					txHash := txn.Hash()
+					if _, txHeight, err := c.GetTransaction(txHash); err == nil && txHeight == h {
+						c.BatchDelete(append([]byte{byte(DATA_Transaction)}, txHash.Bytes()...))
+					}
+				}
+			}
+			c.BatchDelete(headerKey)
+			c.BatchDelete(getBlockUndoKey(hash))
+		}
+		removed++
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.BatchCommit()
+}
+
+// recoverStore repairs the partially written blocks. The current block is
+// moved back to the last complete block, the blocks above it are removed
+// and the indexes are rebuilt from the remaining blocks if they might
+// contain changes of a removed block, or a previous reindex is interrupted.
+func (c *ChainStore) recoverStore() error {
+	currentHash, height, err := c.getStoredCurrentBlock()
+	if err != nil {
+		return err
+	}
+
+	tip := height
+	for tip > 0 && !c.isBlockComplete(tip) {
+		tip--
+	}
+	tipHash, err := c.GetBlockHash(tip)
+	if err != nil {
+		return err
+	}
+	moved := tip != height || !tipHash.IsEqual(currentHash)
+	if moved {
+		log.Warnf("[Recovery] block at height %d is incomplete, move current block back to height %d", height, tip)
+		value := new(bytes.Buffer)
+		if err := tipHash.Serialize(value); err != nil {
+			return err
+		}
+		if err := WriteUint32(value, tip); err != nil {
+			return err
+		}
+		if err := c.Put([]byte{byte(SYS_CurrentBlock)}, value.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	removed, err := c.removeBlocksAbove(tip)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Warnf("[Recovery] removed %d partially written blocks above height %d", removed, tip)
+	}
+
+	_, err = c.Get([]byte{byte(SYS_ReindexPending)})
+	pending := err == nil
+	if !moved && removed == 0 && !pending {
+		return nil
+	}
+
+	// the indexes of a pruned store can not be rebuilt, the pending flag
+	// is kept so the store is refused again at next start up
+	if c.GetPrunedHeight() > 0 {
+		if err := c.Put([]byte{byte(SYS_ReindexPending)}, []byte{byte(ValueExist)}); err != nil {
+			return err
+		}
+		return ErrPrunedReindex
+	}
+
+	log.Warn("[Recovery] rebuilding indexes")
+	return c.Reindex()
+}
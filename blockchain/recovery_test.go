@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecoveryTestStore(t testing.TB) (*ChainStore, *core.Block, *core.Block) {
+	store, genesis := newGenesisTestStore(t)
+
+	coinbase := NewCoinBaseTransaction(&core.PayloadCoinBase{}, 1)
+	coinbase.Outputs = []*core.Output{{
+		AssetID:     genesis.Transactions[1].Hash(),
+		Value:       100,
+		ProgramHash: common.Uint168{0x56, 0x78},
+	}}
+	block := &core.Block{
+		Header: core.Header{
+			Height:   1,
+			Previous: genesis.Hash(),
+		},
+		Transactions: []*core.Transaction{coinbase},
+	}
+
+	return store, genesis, block
+}
+
+func dumpStore(store IStore) map[string]string {
+	entries := make(map[string]string)
+	iter := store.NewIterator(nil)
+	for iter.Next() {
+		entries[string(iter.Key())] = string(iter.Value())
+	}
+	iter.Release()
+	return entries
+}
+
+// withFailingStep inserts a failing step at the given position of steps.
+func withFailingStep(steps []func(c *ChainStore, b *core.Block) error,
+	position int) []func(c *ChainStore, b *core.Block) error {
+	failing := func(c *ChainStore, b *core.Block) error {
+		return errors.New("injected failure")
+	}
+	injected := append([]func(c *ChainStore, b *core.Block) error{}, steps[:position]...)
+	injected = append(injected, failing)
+	return append(injected, steps[position:]...)
+}
+
+func TestChainStore_AtomicPersist(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	// a failure between any steps leaves nothing in store
+	for i := 0; i <= len(blockPersistSteps); i++ {
+		err := store.commitBlockSteps(genesis, withFailingStep(blockPersistSteps, i))
+		assert.Error(t, err)
+		assert.Empty(t, dumpStore(store.IStore), "failure injected at step %d", i)
+	}
+
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+
+	// a failure between any rollback steps leaves the store unchanged
+	entries := dumpStore(store.IStore)
+	for i := 0; i <= len(blockRollbackSteps); i++ {
+		err := store.commitBlockSteps(block, withFailingStep(blockRollbackSteps, i))
+		assert.Error(t, err)
+		assert.Equal(t, entries, dumpStore(store.IStore), "failure injected at step %d", i)
+	}
+}
+
+func TestChainStore_RecoverStore(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+	clean := dumpStore(store.IStore)
+
+	// nothing to recover
+	assert.NoError(t, store.recoverStore())
+	assert.Equal(t, clean, dumpStore(store.IStore))
+
+	// the block is written but the current block is not updated
+	partialSteps := blockPersistSteps[:len(blockPersistSteps)-1]
+	if !assert.NoError(t, store.commitBlockSteps(block, partialSteps)) {
+		return
+	}
+	assert.NoError(t, store.recoverStore())
+	assert.Equal(t, clean, dumpStore(store.IStore))
+
+	// the current block is updated but a transaction is missing
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	coinbaseHash := block.Transactions[0].Hash()
+	assert.NoError(t, store.Delete(append([]byte{byte(DATA_Transaction)}, coinbaseHash.Bytes()...)))
+	assert.NoError(t, store.recoverStore())
+	assert.Equal(t, clean, dumpStore(store.IStore))
+
+	// an interrupted reindex is continued
+	assert.NoError(t, store.Put([]byte{byte(SYS_ReindexPending)}, []byte{byte(ValueExist)}))
+	assert.NoError(t, store.Delete(append([]byte{byte(IX_Unspent)}, genesis.Transactions[0].Hash().Bytes()...)))
+	assert.NoError(t, store.recoverStore())
+	assert.Equal(t, clean, dumpStore(store.IStore))
+
+	// the indexes of a pruned store can not be rebuilt, it is refused at
+	// every start up until it is resynced
+	store.NewBatch()
+	assert.NoError(t, store.batchPutPrunedHeight(1))
+	assert.NoError(t, store.BatchCommit())
+	assert.NoError(t, store.recoverStore())
+	if !assert.NoError(t, store.commitBlockSteps(block, partialSteps)) {
+		return
+	}
+	assert.Equal(t, ErrPrunedReindex, store.recoverStore())
+	assert.Equal(t, ErrPrunedReindex, store.recoverStore())
+	assert.Equal(t, ErrPrunedReindex, store.Reindex())
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA/config"
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// DefaultSigCache is the cache of the verified signatures shared by the
+// transaction pool, the miner and the block validation.
+var DefaultSigCache = NewSigCache(config.Parameters.SigCacheEntries())
+
+// SigCacheStats is the number of the entries in the signature cache and how
+// many lookups are hits or misses.
+type SigCacheStats struct {
+	Entries    int
+	MaxEntries int
+	Hits       uint64
+	Misses     uint64
+}
+
+// SigCache caches the programs whose signatures of the data are verified, so
+// a transaction verified when it enters the transaction pool is not verified
+// again when it is mined or included in a connected block. An arbitrary entry
+// is evicted when the cache is full, and the entries of a transaction are
+// removed when its block is connected.
+type SigCache struct {
+	sync.RWMutex
+	entries    map[Uint256]struct{}
+	maxEntries int
+	hits       uint64
+	misses     uint64
+}
+
+func NewSigCache(maxEntries int) *SigCache {
+	return &SigCache{
+		entries:    make(map[Uint256]struct{}),
+		maxEntries: maxEntries,
+	}
+}
+
+// sigCacheKey returns the key of the program verified with the data of the
+// hash. The code and the parameter are written with their lengths, so the
+// same bytes split at another point are not the same key.
+func sigCacheKey(dataHash Uint256, program *Program) Uint256 {
+	hash := sha256.New()
+	hash.Write(dataHash[:])
+	WriteVarBytes(hash, program.Code)
+	WriteVarBytes(hash, program.Parameter)
+	var key Uint256
+	copy(key[:], hash.Sum(nil))
+	return key
+}
+
+// Exists returns if the program of the key is verified.
+func (c *SigCache) Exists(key Uint256) bool {
+	c.RLock()
+	_, ok := c.entries[key]
+	c.RUnlock()
+
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return ok
+}
+
+// Add adds the key of a verified program, an arbitrary entry is evicted if
+// the cache is full.
+func (c *SigCache) Add(key Uint256) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = struct{}{}
+}
+
+// RemoveTransaction removes the entries of the programs of the transaction,
+// which are not verified again after its block is connected.
+func (c *SigCache) RemoveTransaction(txn *Transaction) {
+	buf := new(bytes.Buffer)
+	txn.SerializeUnsigned(buf)
+	dataHash := Uint256(sha256.Sum256(buf.Bytes()))
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, program := range txn.Programs {
+		delete(c.entries, sigCacheKey(dataHash, program))
+	}
+}
+
+// Stats returns the number of the entries and the lookups of the cache.
+func (c *SigCache) Stats() SigCacheStats {
+	c.RLock()
+	entries := len(c.entries)
+	c.RUnlock()
+
+	return SigCacheStats{
+		Entries:    entries,
+		MaxEntries: c.maxEntries,
+		Hits:       atomic.LoadUint64(&c.hits),
+		Misses:     atomic.LoadUint64(&c.misses),
+	}
+}
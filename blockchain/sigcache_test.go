@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigCache(t *testing.T) {
+	cache := NewSigCache(2)
+	program := &core.Program{Code: []byte{1}, Parameter: []byte{2}}
+	key1 := sigCacheKey(common.Uint256{1}, program)
+	key2 := sigCacheKey(common.Uint256{2}, program)
+	key3 := sigCacheKey(common.Uint256{3}, program)
+	assert.NotEqual(t, key1, key2)
+
+	// the same bytes split between the code and the parameter at another
+	// point are another program
+	assert.NotEqual(t, key1, sigCacheKey(common.Uint256{1},
+		&core.Program{Code: []byte{1, 2}, Parameter: []byte{}}))
+	assert.NotEqual(t, key1, sigCacheKey(common.Uint256{1},
+		&core.Program{Code: []byte{}, Parameter: []byte{1, 2}}))
+
+	assert.False(t, cache.Exists(key1))
+	cache.Add(key1)
+	cache.Add(key2)
+	assert.True(t, cache.Exists(key1))
+	assert.True(t, cache.Exists(key2))
+
+	// an entry is evicted when the cache is full
+	cache.Add(key3)
+	assert.True(t, cache.Exists(key3))
+	assert.Equal(t, 2, cache.Stats().Entries)
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.MaxEntries)
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	// a cache without entries caches nothing
+	cache = NewSigCache(0)
+	cache.Add(key1)
+	assert.False(t, cache.Exists(key1))
+}
+
+func TestSigCache_RunPrograms(t *testing.T) {
+	cache := DefaultSigCache
+	defer func() { DefaultSigCache = cache }()
+	DefaultSigCache = NewSigCache(100)
+
+	check := newSigChecks(t, 1)[0]
+	assert.NoError(t, check.run())
+	assert.Equal(t, len(check.tx.Programs), DefaultSigCache.Stats().Entries)
+	assert.Equal(t, uint64(0), DefaultSigCache.Stats().Hits)
+
+	// the signatures are not verified again
+	assert.NoError(t, check.run())
+	assert.Equal(t, uint64(len(check.tx.Programs)), DefaultSigCache.Stats().Hits)
+
+	// an invalid signature is not a hit
+	var program *core.Program
+	for _, p := range check.tx.Programs {
+		if len(p.Parameter) == crypto.SignatureScriptLength {
+			program = p
+		}
+	}
+	program.Parameter = append([]byte{}, program.Parameter...)
+	program.Parameter[10] ^= 0xff
+	assert.Error(t, check.run())
+
+	// the entries are removed when the block is connected
+	program.Parameter[10] ^= 0xff
+	DefaultSigCache.RemoveTransaction(check.tx)
+	assert.Equal(t, 0, DefaultSigCache.Stats().Entries)
+}
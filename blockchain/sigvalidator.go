@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA/core"
+)
+
+// sigCheck is a transaction whose signatures are checked, with the outputs
+// it spends and the height of the block including it.
+type sigCheck struct {
+	tx         *Transaction
+	references map[*Input]*Output
+	height     uint32
+}
+
+func (c *sigCheck) run() error {
+	if err := CheckTransactionSignature(c.tx, c.references, c.height); err != nil {
+		return fmt.Errorf("transaction %s signature check failed, %s", c.tx.Hash().String(), err)
+	}
+	return nil
+}
+
+// sigCheckWorkers returns the number of goroutines checking the signatures
+// of a block, which is the number of cores set by MultiCoreNum.
+func sigCheckWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// checkSignatures checks the signatures of the transactions concurrently with
+// at most the given number of workers. It returns the error of the first
+// invalid transaction found, and the transactions not checked yet are
+// skipped.
+func checkSignatures(checks []sigCheck, workers int) error {
+	if workers > len(checks) {
+		workers = len(checks)
+	}
+	if workers <= 1 {
+		for i := range checks {
+			if err := checks[i].run(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	jobs := make(chan *sigCheck)
+	quit := make(chan struct{})
+	errs := make(chan error, workers)
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for check := range jobs {
+				if err := check.run(); err != nil {
+					errs <- err
+					once.Do(func() { close(quit) })
+					return
+				}
+			}
+		}()
+	}
+
+send:
+	for i := range checks {
+		select {
+		case jobs <- &checks[i]:
+		case <-quit:
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSigChecks returns the signature checks of a synthetic block, each
+// transaction spends an output of a standard account and an output of a
+// 3 of 5 multisig account.
+func newSigChecks(t testing.TB, num int) []sigCheck {
+	checks := make([]sigCheck, 0, num)
+	for i := 0; i < num; i++ {
+		acts := []act{newAccount(t), newMultiAccount(5, t)}
+		tx := &core.Transaction{
+			TxType:  core.TransferAsset,
+			Payload: new(core.PayloadTransferAsset),
+			Outputs: []*core.Output{{Value: 1, ProgramHash: *acts[0].ProgramHash()}},
+		}
+		references := make(map[*core.Input]*core.Output)
+		for _, act := range acts {
+			var txID common.Uint256
+			rand.Read(txID[:])
+			input := &core.Input{Previous: *core.NewOutPoint(txID, 0)}
+			tx.Inputs = append(tx.Inputs, input)
+			references[input] = &core.Output{Value: 1, ProgramHash: *act.ProgramHash()}
+		}
+		data := getData(tx)
+		for _, act := range acts {
+			signature, err := act.Sign(data)
+			if err != nil {
+				t.Fatalf("Generate signature failed, error %s", err.Error())
+			}
+			tx.Programs = append(tx.Programs, &core.Program{Code: act.RedeemScript(), Parameter: signature})
+		}
+		checks = append(checks, sigCheck{tx: tx, references: references})
+	}
+	return checks
+}
+
+func TestCheckSignatures(t *testing.T) {
+	checks := newSigChecks(t, 20)
+	assert.NoError(t, checkSignatures(nil, 4))
+	assert.NoError(t, checkSignatures(checks, 1))
+	assert.NoError(t, checkSignatures(checks, 4))
+
+	// an invalid signature fails all the transactions whichever worker
+	// checks it
+	badTx := checks[13].tx
+	for _, program := range badTx.Programs {
+		if len(program.Parameter) == crypto.SignatureScriptLength {
+			program.Parameter[10] ^= 0xff
+		}
+	}
+	for _, workers := range []int{1, 4, 100} {
+		err := checkSignatures(checks, workers)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), badTx.Hash().String())
+		}
+	}
+}
+
+func benchmarkCheckSignatures(b *testing.B, workers int) {
+	// verify the signatures in each round
+	cache := DefaultSigCache
+	defer func() { DefaultSigCache = cache }()
+	DefaultSigCache = NewSigCache(0)
+
+	checks := newSigChecks(b, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := checkSignatures(checks, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckSignatures_Serial(b *testing.B) {
+	benchmarkCheckSignatures(b, 1)
+}
+
+func BenchmarkCheckSignatures_Parallel(b *testing.B) {
+	benchmarkCheckSignatures(b, sigCheckWorkers())
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"bytes"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// SpentBy is an entry of the spent-by index, it records the transaction which
+// spent an output and the height of the block it was packed in.
+type SpentBy struct {
+	TxId   Uint256
+	Height uint32
+}
+
+// key: IX_Spent_By || tx hash || index
+func getSpentByKey(outPoint *OutPoint) ([]byte, error) {
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(IX_Spent_By))
+	if err := outPoint.Serialize(key); err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+// value: spender tx hash || height
+func (c *ChainStore) PersistSpentBy(txn *Transaction, height uint32) error {
+	if txn.IsCoinBaseTx() {
+		return nil
+	}
+
+	value := new(bytes.Buffer)
+	txId := txn.Hash()
+	if err := txId.Serialize(value); err != nil {
+		return err
+	}
+	if err := WriteUint32(value, height); err != nil {
+		return err
+	}
+
+	for _, input := range txn.Inputs {
+		key, err := getSpentByKey(&input.Previous)
+		if err != nil {
+			return err
+		}
+		c.BatchPut(key, value.Bytes())
+	}
+	return nil
+}
+
+func (c *ChainStore) RollbackSpentBy(txn *Transaction) error {
+	if txn.IsCoinBaseTx() {
+		return nil
+	}
+
+	for _, input := range txn.Inputs {
+		key, err := getSpentByKey(&input.Previous)
+		if err != nil {
+			return err
+		}
+		c.BatchDelete(key)
+	}
+	return nil
+}
+
+// GetSpentBy returns the transaction which spent the given output, an error
+// is returned if the output is not spent in the persisted blocks.
+func (c *ChainStore) GetSpentBy(txId Uint256, index uint16) (*SpentBy, error) {
+	key, err := getSpentByKey(&OutPoint{TxID: txId, Index: index})
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(data)
+	spentBy := new(SpentBy)
+	if err := spentBy.TxId.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if spentBy.Height, err = ReadUint32(r); err != nil {
+		return nil, err
+	}
+	return spentBy, nil
+}
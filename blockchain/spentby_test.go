@@ -0,0 +1,58 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainStore_SpentBy(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	coinbase := genesis.Transactions[0]
+	spender := &core.Transaction{
+		TxType:  core.TransferAsset,
+		Payload: &core.PayloadTransferAsset{},
+		Inputs: []*core.Input{{
+			Previous: core.OutPoint{TxID: coinbase.Hash(), Index: 0},
+		}},
+		Outputs: []*core.Output{{
+			AssetID:     coinbase.Outputs[0].AssetID,
+			Value:       coinbase.Outputs[0].Value,
+			ProgramHash: common.Uint168{0x56, 0x78},
+		}},
+	}
+	block.Transactions = append(block.Transactions, spender)
+
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+	_, err := store.GetSpentBy(coinbase.Hash(), 0)
+	assert.Error(t, err)
+
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	spentBy, err := store.GetSpentBy(coinbase.Hash(), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, spender.Hash(), spentBy.TxId)
+		assert.Equal(t, uint32(1), spentBy.Height)
+	}
+	// coinbase of the block spends nothing
+	_, err = store.GetSpentBy(coinbase.Hash(), 1)
+	assert.Error(t, err)
+
+	problems, err := checkTestDB(t, store)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	_, err = store.GetSpentBy(coinbase.Hash(), 0)
+	assert.Error(t, err)
+}
@@ -21,4 +21,14 @@ type IStore interface {
 	BatchCommit() error
 	Close() error
 	NewIterator(prefix []byte) IIterator
+	NewSnapshot() (IStoreSnapshot, error)
+}
+
+// IStoreSnapshot is a read only view of the store at the time it is taken,
+// the batches committed after that are not seen. It must be released after
+// use.
+type IStoreSnapshot interface {
+	Get(key []byte) ([]byte, error)
+	NewIterator(prefix []byte) IIterator
+	Release()
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA/config"
@@ -19,8 +20,16 @@ type TxPool struct {
 	txnCnt  uint64                   // count
 	txnList map[Uint256]*Transaction // transaction which have been verifyed will put into this map
 	//issueSummary  map[Uint256]Fixed64           // transaction which pass the verify will summary the amout to this map
-	inputUTXOList   map[string]*Transaction  // transaction which pass the verify will add the UTXO to this map
-	sidechainTxList map[Uint256]*Transaction // sidechain tx pool
+	inputUTXOList   map[string]*Transaction             // transaction which pass the verify will add the UTXO to this map
+	txnEntries      map[Uint256]*txEntry                // acceptance time and height of the transactions in txnList
+	sidechainTxList map[Uint256]*Transaction            // sidechain tx pool
+	txnSize         int                                 // total size of the transactions in txnList
+	minFeePerKB     Fixed64                             // min fee per KB raised by the last eviction
+	minFeeTime      time.Time                           // time of the last raise of minFeePerKB
+	orphans         map[Uint256]*orphanTx               // transactions whose parents are not arrived
+	orphansByPrev   map[string]map[Uint256]*Transaction // orphans indexed by the spent out points
+	nextOrphanScan  time.Time                           // time of the next scan of the expired orphans
+	feeEstimator    *FeeEstimator                       // estimator of the fees by the blocks the transactions take to be included
 }
 
 func (pool *TxPool) Init() {
@@ -30,12 +39,26 @@ func (pool *TxPool) Init() {
 	pool.inputUTXOList = make(map[string]*Transaction)
 	//pool.issueSummary = make(map[Uint256]Fixed64)
 	pool.txnList = make(map[Uint256]*Transaction)
+	pool.txnEntries = make(map[Uint256]*txEntry)
+	pool.feeEstimator = NewFeeEstimator()
 	pool.sidechainTxList = make(map[Uint256]*Transaction)
+	pool.txnSize = 0
+	pool.minFeePerKB = 0
+	pool.minFeeTime = time.Time{}
+	pool.orphans = make(map[Uint256]*orphanTx)
+	pool.orphansByPrev = make(map[string]map[Uint256]*Transaction)
+	pool.nextOrphanScan = time.Now().Add(orphanExpireScanInterval)
 }
 
 //append transaction to txnpool when check ok.
 //1.check  2.check with ledger(db) 3.check with pool
 func (pool *TxPool) AppendToTxnPool(txn *Transaction) ErrCode {
+	return pool.appendToTxnPool(txn, newTxEntry())
+}
+
+// appendToTxnPool appends the transaction accepted at the time and the height
+// of the entry.
+func (pool *TxPool) appendToTxnPool(txn *Transaction, entry *txEntry) ErrCode {
 
 	if txn.IsCoinBaseTx() {
 		log.Warn("coinbase cannot be added into transaction pool", txn.Hash().String())
@@ -43,30 +66,47 @@ func (pool *TxPool) AppendToTxnPool(txn *Transaction) ErrCode {
 	}
 
 	//verify transaction with Concurrency
-	if errCode := CheckTransactionSanity(CheckTxOut, txn); errCode != Success {
+	nextHeight := DefaultLedger.Blockchain.GetBestHeight() + 1
+	if errCode := CheckTransactionSanity(CheckTxOut, txn, nextHeight); errCode != Success {
 		log.Warn("[TxPool CheckTransactionSanity] failed", txn.Hash().String())
 		return errCode
 	}
-	if errCode := CheckTransactionContext(txn); errCode != Success {
+	//the transaction may spend the outputs of the transactions in pool
+	lookup := newParentLookup(pool)
+	if errCode := CheckChainedTransactionContext(txn, lookup); errCode != Success {
 		log.Warn("[TxPool CheckTransactionContext] failed", txn.Hash().String())
 		return errCode
 	}
-	//verify transaction by pool with lock
-	if errCode := pool.verifyTransactionWithTxnPool(txn); errCode != Success {
-		log.Warn("[TxPool verifyTransactionWithTxnPool] failed", txn.Hash())
-		return errCode
-	}
 
-	txn.Fee = GetTxFee(txn, DefaultLedger.Blockchain.AssetID)
+	txn.Fee = GetChainedTxFee(txn, DefaultLedger.Blockchain.AssetID, lookup)
 	buf := new(bytes.Buffer)
 	txn.Serialize(buf)
 	txn.FeePerKB = txn.Fee * 1000 / Fixed64(len(buf.Bytes()))
-	//add the transaction to process scope
-	if ok := pool.addToTxList(txn); !ok {
-		// reject duplicated transaction
-		log.Debugf("Transaction duplicate %s", txn.Hash().String())
-		return ErrTransactionDuplicate
+	if minFee := pool.GetMinFeePerKB(); txn.FeePerKB < minFee {
+		log.Warnf("[TxPool] transaction %s fee per KB %d is lower than the min fee %d",
+			txn.Hash().String(), txn.FeePerKB, minFee)
+		return ErrInsufficientFee
+	}
+	if err := pool.checkChainLimits(txn); err != nil {
+		log.Warn("[TxPool checkChainLimits] failed", err)
+		return ErrTxChainTooLong
+	}
+
+	if txn.IsSideChainPowTx() {
+		// check and replace the duplicate sidechainpow tx
+		pool.replaceDuplicateSideChainPowTx(txn)
+	}
+	//verify transaction by pool and add it to process scope with lock
+	replaced, errCode := pool.acceptTransaction(txn, lookup.parents, entry)
+	if errCode != Success {
+		log.Warn("[TxPool acceptTransaction] failed", txn.Hash())
+		return errCode
+	}
+	if len(replaced) > 0 {
+		DefaultLedger.Blockchain.BCEvents.Notify(events.EventTransactionReplaced,
+			&TxReplacement{Transaction: txn, Replaced: replaced})
 	}
+	DefaultLedger.Blockchain.BCEvents.Notify(events.EventNewTransactionPutInPool, txn)
 	return Success
 }
 
@@ -95,9 +135,13 @@ func (pool *TxPool) GetTransactionPool(hasMaxCount bool) map[Uint256]*Transactio
 
 //clean the trasaction Pool with committed block.
 func (pool *TxPool) CleanSubmittedTransactions(block *Block) error {
+	// the transactions are tracked before they are removed from the pool
+	pool.feeEstimator.processBlock(block, pool)
 	pool.cleanTransactions(block.Transactions)
 	pool.cleanSidechainTx(block.Transactions)
 	pool.cleanSideChainPowTx()
+	pool.cleanOrphans(block.Transactions)
+	pool.expireTransactions(time.Now())
 
 	return nil
 }
@@ -130,7 +174,12 @@ func (pool *TxPool) cleanTransactions(blockTxs []*Transaction) error {
 						"Delete transaction in the transaction pool. "+
 						"block transaction hash: %x, transaction hash: %x, the same input: %s, index: %d",
 						blockTx.Hash(), tx.Hash(), input.Previous.TxID, input.Previous.Index)
+					// the transactions spending the outputs of the double
+					// spent transaction are invalid too
+					deleteCount += pool.removeWithSpenders(tx)
+					continue
 				}
+
 				//1.remove from txnList
 				pool.delFromTxList(tx.Hash())
 				//2.remove from UTXO list map
@@ -165,65 +214,16 @@ func (pool *TxPool) GetTransaction(hash Uint256) *Transaction {
 	return pool.txnList[hash]
 }
 
-//verify transaction with txnpool
-func (pool *TxPool) verifyTransactionWithTxnPool(txn *Transaction) ErrCode {
-	if txn.IsSideChainPowTx() {
-		// check and replace the duplicate sidechainpow tx
-		pool.replaceDuplicateSideChainPowTx(txn)
-	} else if txn.IsWithdrawFromSideChainTx() {
-		// check if the withdraw transaction includes duplicate sidechain tx in pool
-		if err := pool.verifyDuplicateSidechainTx(txn); err != nil {
-			log.Warn(err)
-			return ErrSidechainTxDuplicate
-		}
-	}
-
-	// check if the transaction includes double spent UTXO inputs
-	if err := pool.verifyDoubleSpend(txn); err != nil {
-		log.Warn(err)
-		return ErrDoubleSpend
-	}
-
-	return Success
-}
-
 //remove from associated map
 func (pool *TxPool) removeTransaction(txn *Transaction) {
 	//1.remove from txnList
 	pool.delFromTxList(txn.Hash())
 	//2.remove from UTXO list map
-	result, err := DefaultLedger.Store.GetTxReference(txn)
-	if err != nil {
-		log.Info(fmt.Sprintf("Transaction =%x not Exist in Pool when delete.", txn.Hash()))
-		return
-	}
-	for UTXOTxInput := range result {
-		pool.delInputUTXOList(UTXOTxInput)
+	for _, input := range txn.Inputs {
+		pool.delInputUTXOList(input)
 	}
 }
 
-//check and add to utxo list pool
-func (pool *TxPool) verifyDoubleSpend(txn *Transaction) error {
-	reference, err := DefaultLedger.Store.GetTxReference(txn)
-	if err != nil {
-		return err
-	}
-	inputs := []*Input{}
-	for k := range reference {
-		if txn := pool.getInputUTXOList(k); txn != nil {
-			return errors.New(fmt.Sprintf("double spent UTXO inputs detected, "+
-				"transaction hash: %x, input: %s, index: %d",
-				txn.Hash(), k.Previous.TxID, k.Previous.Index))
-		}
-		inputs = append(inputs, k)
-	}
-	for _, v := range inputs {
-		pool.addInputUTXOList(txn, v)
-	}
-
-	return nil
-}
-
 func (pool *TxPool) IsDuplicateSidechainTx(sidechainTxHash Uint256) bool {
 	_, ok := pool.sidechainTxList[sidechainTxHash]
 	if ok {
@@ -312,6 +312,8 @@ func (pool *TxPool) cleanSideChainPowTx() {
 			if err = CheckSideChainPowConsensus(txn, arbitrtor); err != nil {
 				// delete tx
 				delete(pool.txnList, hash)
+				delete(pool.txnEntries, hash)
+				pool.txnSize -= txn.GetSize()
 				//delete utxo map
 				for _, input := range txn.Inputs {
 					delete(pool.inputUTXOList, input.ReferKey())
@@ -322,6 +324,7 @@ func (pool *TxPool) cleanSideChainPowTx() {
 }
 
 func (pool *TxPool) addToTxList(txn *Transaction) bool {
+	entry := newTxEntry()
 	pool.Lock()
 	defer pool.Unlock()
 	txnHash := txn.Hash()
@@ -329,17 +332,21 @@ func (pool *TxPool) addToTxList(txn *Transaction) bool {
 		return false
 	}
 	pool.txnList[txnHash] = txn
-	DefaultLedger.Blockchain.BCEvents.Notify(events.EventNewTransactionPutInPool, txn)
+	pool.txnEntries[txnHash] = entry
+	pool.txnSize += txn.GetSize()
 	return true
 }
 
 func (pool *TxPool) delFromTxList(txId Uint256) bool {
 	pool.Lock()
 	defer pool.Unlock()
-	if _, ok := pool.txnList[txId]; !ok {
+	txn, ok := pool.txnList[txId]
+	if !ok {
 		return false
 	}
 	delete(pool.txnList, txId)
+	delete(pool.txnEntries, txId)
+	pool.txnSize -= txn.GetSize()
 	return true
 }
 
@@ -444,13 +451,19 @@ func (pool *TxPool) RemoveTransaction(txn *Transaction) {
 
 		txn := pool.getInputUTXOList(&input)
 		if txn != nil {
-			pool.removeTransaction(txn)
+			pool.removeWithSpenders(txn)
 		}
 	}
 }
 
 func GetTxFee(tx *Transaction, assetId Uint256) Fixed64 {
-	feeMap, err := GetTxFeeMap(tx)
+	return GetChainedTxFee(tx, assetId, nil)
+}
+
+// GetChainedTxFee returns the fee of a transaction which may spend the
+// outputs of the given unconfirmed transactions.
+func GetChainedTxFee(tx *Transaction, assetId Uint256, unconfirmed TxLookup) Fixed64 {
+	feeMap, err := getTxFeeMap(tx, unconfirmed)
 	if err != nil {
 		return 0
 	}
@@ -459,8 +472,12 @@ func GetTxFee(tx *Transaction, assetId Uint256) Fixed64 {
 }
 
 func GetTxFeeMap(tx *Transaction) (map[Uint256]Fixed64, error) {
+	return getTxFeeMap(tx, nil)
+}
+
+func getTxFeeMap(tx *Transaction, unconfirmed TxLookup) (map[Uint256]Fixed64, error) {
 	feeMap := make(map[Uint256]Fixed64)
-	reference, err := DefaultLedger.Store.GetTxReference(tx)
+	reference, err := getTxReference(tx, unconfirmed)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	. "github.com/elastos/Elastos.ELA/errors"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// TestAcceptTransaction runs the checks of AppendToTxnPool on the transaction
+// without appending it to the pool. It returns the fee of the transaction and
+// the rule it fails, the fee is zero if the outputs it spends are unknown.
+func (pool *TxPool) TestAcceptTransaction(txn *Transaction) (Fixed64, *RuleError) {
+	if pool.GetTransaction(txn.Hash()) != nil {
+		return 0, NewRuleError(ErrTransactionDuplicate, "TxPool",
+			errors.New("transaction is already in pool"))
+	}
+	if txn.IsCoinBaseTx() {
+		return 0, NewRuleError(ErrIneffectiveCoinbase, "TxPool",
+			errors.New("coinbase cannot be added into transaction pool"))
+	}
+
+	nextHeight := DefaultLedger.Blockchain.GetBestHeight() + 1
+	if err := checkTransactionSanity(CheckTxOut, txn, nextHeight); err != nil {
+		return 0, err
+	}
+
+	txn.Fee = GetChainedTxFee(txn, DefaultLedger.Blockchain.AssetID, pool)
+	txn.FeePerKB = txn.Fee * 1000 / Fixed64(txn.GetSize())
+
+	if err := checkTransactionContextRules(txn, pool, true); err != nil {
+		return txn.Fee, err
+	}
+	if minFee := pool.GetMinFeePerKB(); txn.FeePerKB < minFee {
+		return txn.Fee, NewRuleError(ErrInsufficientFee, "TxPool",
+			fmt.Errorf("fee per KB %s is lower than the min fee %s", txn.FeePerKB.String(), minFee.String()))
+	}
+	if err := pool.checkChainLimits(txn); err != nil {
+		return txn.Fee, NewRuleError(ErrTxChainTooLong, "checkChainLimits", err)
+	}
+	if err := pool.checkConflicts(txn); err != nil {
+		return txn.Fee, err
+	}
+	return txn.Fee, nil
+}
+
+// checkConflicts returns the rule the transaction fails if it spends the
+// same outputs, or withdraws the same sidechain transactions, as the pooled
+// transactions it can not replace.
+func (pool *TxPool) checkConflicts(txn *Transaction) *RuleError {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	_, err := pool.getConflicts(txn)
+	return err
+}
+
+// getConflicts returns the pooled transactions the transaction replaces, and
+// the rule it fails if it conflicts with the pooled transactions it can not
+// replace. The caller must hold the lock.
+func (pool *TxPool) getConflicts(txn *Transaction) (map[Uint256]*Transaction, *RuleError) {
+	replaced, err := pool.getReplaced(txn)
+	if err != nil {
+		return nil, NewRuleError(ErrDoubleSpend, "replaceConflicts", err)
+	}
+
+	for _, input := range txn.Inputs {
+		if poolTx, ok := pool.inputUTXOList[input.ReferKey()]; ok {
+			if _, ok := replaced[poolTx.Hash()]; !ok {
+				return nil, NewRuleError(ErrDoubleSpend, "verifyDoubleSpend",
+					fmt.Errorf("double spent UTXO inputs detected, transaction hash: %x, input: %s, index: %d",
+						poolTx.Hash(), input.Previous.TxID.String(), input.Previous.Index))
+			}
+		}
+	}
+
+	if txn.IsWithdrawFromSideChainTx() {
+		payload := txn.Payload.(*PayloadWithdrawFromSideChain)
+		for _, hash := range payload.SideChainTransactionHashes {
+			if poolTx, ok := pool.sidechainTxList[hash]; ok {
+				if _, ok := replaced[poolTx.Hash()]; !ok {
+					return nil, NewRuleError(ErrSidechainTxDuplicate, "verifyDuplicateSidechainTx",
+						fmt.Errorf("duplicate sidechain tx detected %s", hash.String()))
+				}
+			}
+		}
+	}
+	return replaced, nil
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_CheckConflicts(t *testing.T) {
+	pool := newTestTxPool()
+
+	final := newTestTx(100, 400, math.MaxUint32, core.OutPoint{TxID: common.Uint256{1}})
+	replaceable := newTestTx(100, 400, 0, core.OutPoint{TxID: common.Uint256{2}})
+	addTestTx(pool, final)
+	addTestTx(pool, replaceable)
+
+	// a transaction not conflicting with the pool
+	assert.Nil(t, pool.checkConflicts(newTestTx(100, 400, 0, core.OutPoint{TxID: common.Uint256{3}})))
+
+	// a transaction double spending a transaction not replaceable
+	err := pool.checkConflicts(newTestTx(1000, 4000, 0, final.Inputs[0].Previous))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.ErrDoubleSpend, err.Code)
+		assert.Equal(t, "verifyDoubleSpend", err.Rule)
+	}
+
+	// a replacement paying a lower fee
+	err = pool.checkConflicts(newTestTx(50, 4000, 0, replaceable.Inputs[0].Previous))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.ErrDoubleSpend, err.Code)
+		assert.Equal(t, "replaceConflicts", err.Rule)
+	}
+
+	// a valid replacement, the pool is not changed
+	assert.Nil(t, pool.checkConflicts(newTestTx(1000, 4000, 0, replaceable.Inputs[0].Previous)))
+	assert.NotNil(t, pool.GetTransaction(replaceable.Hash()))
+	assert.Equal(t, replaceable, pool.getInputUTXOList(replaceable.Inputs[0]))
+}
+
+func TestCheckTransactionSanityRule(t *testing.T) {
+	// a transfer transaction without inputs
+	txn := newSpendTransaction()
+	txn.Outputs = []*core.Output{{Value: 1}}
+	err := checkTransactionSanity(core.CheckTxOut, txn, 0)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.ErrInvalidInput, err.Code)
+		assert.Equal(t, "CheckTransactionInput", err.Rule)
+		assert.Contains(t, err.Error(), "[CheckTransactionInput], ")
+	}
+	assert.Equal(t, errors.ErrInvalidInput, CheckTransactionSanity(core.CheckTxOut, txn, 0))
+}
+
+func TestTxPool_TestAcceptTransaction(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 1)
+	defer restore()
+	pool := newTestTxPool()
+
+	// the transaction is checked but not appended
+	txn := l.spend(t, 1000, l.depositOutPoint(0))
+	fee, err := pool.TestAcceptTransaction(txn)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Fixed64(1000), fee)
+	assert.Equal(t, 0, pool.GetTransactionCount())
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(txn))
+
+	// the rule failed by the double spend is the one AppendToTxnPool fails
+	conflict := l.spend(t, 500, l.depositOutPoint(0))
+	fee, err = pool.TestAcceptTransaction(conflict)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.ErrDoubleSpend, err.Code)
+	}
+	assert.Equal(t, common.Fixed64(500), fee)
+	assert.Equal(t, errors.ErrDoubleSpend, pool.AppendToTxnPool(conflict))
+
+	_, err = pool.TestAcceptTransaction(txn)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.ErrTransactionDuplicate, err.Code)
+	}
+}
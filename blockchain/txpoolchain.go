@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// MaxUnconfirmedAncestors is the max number of the transactions in pool
+	// a transaction in pool depends on.
+	MaxUnconfirmedAncestors = 25
+
+	// MaxUnconfirmedDescendants is the max number of the transactions in
+	// pool depending on a transaction in pool.
+	MaxUnconfirmedDescendants = 25
+)
+
+// A transaction in pool can spend the outputs of other transactions in pool,
+// they are its parents. The ancestors of a transaction are its parents and
+// the ancestors of them, the descendants are the transactions spending its
+// outputs and the descendants of them. The relationships are tracked by the
+// txnList and inputUTXOList of the pool, the inputUTXOList is indexed by the
+// spent out points, so it gives the spenders of the outputs.
+
+// getParents returns the transactions in pool the transaction spends from,
+// the caller must hold the lock.
+func (pool *TxPool) getParents(txn *Transaction) []*Transaction {
+	var parents []*Transaction
+	seen := make(map[Uint256]struct{})
+	for _, input := range txn.Inputs {
+		txId := input.Previous.TxID
+		if _, ok := seen[txId]; ok {
+			continue
+		}
+		seen[txId] = struct{}{}
+		if parent, ok := pool.txnList[txId]; ok {
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// parentLookup is the TxLookup of the pool recording the parents found in
+// pool, they may be removed once they are looked up, so the transaction is
+// accepted only if they are still in pool.
+type parentLookup struct {
+	pool    *TxPool
+	parents TxMap
+}
+
+func newParentLookup(pool *TxPool) *parentLookup {
+	return &parentLookup{pool: pool, parents: make(TxMap)}
+}
+
+func (l *parentLookup) GetTransaction(hash Uint256) *Transaction {
+	txn := l.pool.GetTransaction(hash)
+	if txn != nil {
+		l.parents[hash] = txn
+	}
+	return txn
+}
+
+// collectAncestors adds the ancestors of the transaction to the given map,
+// the caller must hold the lock.
+func (pool *TxPool) collectAncestors(txn *Transaction, ancestors map[Uint256]*Transaction) {
+	for _, parent := range pool.getParents(txn) {
+		parentHash := parent.Hash()
+		if _, ok := ancestors[parentHash]; ok {
+			continue
+		}
+		ancestors[parentHash] = parent
+		pool.collectAncestors(parent, ancestors)
+	}
+}
+
+// checkChainLimits checks the transaction does not have too many ancestors in
+// pool, and none of its ancestors has too many descendants once it is added.
+func (pool *TxPool) checkChainLimits(txn *Transaction) error {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	ancestors := make(map[Uint256]*Transaction)
+	pool.collectAncestors(txn, ancestors)
+	if len(ancestors) > MaxUnconfirmedAncestors {
+		return fmt.Errorf("transaction %s has %d unconfirmed ancestors, more than %d",
+			txn.Hash().String(), len(ancestors), MaxUnconfirmedAncestors)
+	}
+
+	for hash, ancestor := range ancestors {
+		descendants := make(map[Uint256]*Transaction)
+		pool.collectSpenders(ancestor, descendants)
+		// the ancestor itself is collected, and the transaction is added
+		if len(descendants) > MaxUnconfirmedDescendants {
+			return fmt.Errorf("transaction %s in pool has %d unconfirmed descendants, no more is allowed",
+				hash.String(), len(descendants)-1)
+		}
+	}
+	return nil
+}
+
+// removeWithSpenders removes the transaction and its descendants from the
+// pool, and returns the number of the removed transactions.
+func (pool *TxPool) removeWithSpenders(txn *Transaction) int {
+	pool.Lock()
+	defer pool.Unlock()
+
+	count := len(pool.txnList)
+	pool.evictTransaction(txn)
+	return count - len(pool.txnList)
+}
@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_ChainLimits(t *testing.T) {
+	pool := newTestTxPool()
+
+	newTx := func(outPoints ...core.OutPoint) *core.Transaction {
+		txn := newTestTx(0, 0, 0, outPoints...)
+		txn.Outputs = append(txn.Outputs, &core.Output{Value: 1})
+		return txn
+	}
+
+	// a chain of MaxUnconfirmedAncestors + 1 transactions
+	chain := []*core.Transaction{newTx(core.OutPoint{TxID: common.Uint256{1}})}
+	addTestTx(pool, chain[0])
+	for i := 0; i < MaxUnconfirmedAncestors; i++ {
+		txn := newTx(core.OutPoint{TxID: chain[i].Hash()})
+		assert.NoError(t, pool.checkChainLimits(txn))
+		addTestTx(pool, txn)
+		chain = append(chain, txn)
+	}
+
+	// one more transaction exceeds the ancestors limit
+	last := chain[len(chain)-1]
+	assert.Error(t, pool.checkChainLimits(newTx(core.OutPoint{TxID: last.Hash()})))
+
+	// the first transaction has MaxUnconfirmedDescendants descendants, no
+	// more is allowed
+	assert.Error(t, pool.checkChainLimits(newTx(core.OutPoint{TxID: chain[0].Hash(), Index: 1})))
+	// a transaction spending outside the chain is not limited
+	assert.NoError(t, pool.checkChainLimits(newTx(core.OutPoint{TxID: common.Uint256{2}})))
+
+	// removing a transaction removes its descendants
+	assert.Equal(t, 6, pool.removeWithSpenders(chain[20]))
+	assert.Equal(t, 20, pool.GetTransactionCount())
+	for _, txn := range chain[20:] {
+		assert.Nil(t, pool.GetTransaction(txn.Hash()))
+		assert.Nil(t, pool.getInputUTXOList(txn.Inputs[0]))
+	}
+	assert.NoError(t, pool.checkChainLimits(newTx(core.OutPoint{TxID: chain[19].Hash(), Index: 1})))
+}
+
+func TestTxPool_ReplaceChainedTransaction(t *testing.T) {
+	pool := newTestTxPool()
+
+	// tx2 does not opt in, but inherits the replaceability of tx1
+	tx1 := newTestTx(100, 400, 0, core.OutPoint{TxID: common.Uint256{1}})
+	tx2 := newTestTx(100, 400, ^uint32(0), core.OutPoint{TxID: tx1.Hash()})
+	addTestTx(pool, tx1)
+	addTestTx(pool, tx2)
+
+	// a replacement spending the output of the replaced transaction
+	spending := newTestTx(1000, 4000, 0, tx1.Inputs[0].Previous)
+	spending.Inputs = append(spending.Inputs, &core.Input{Previous: core.OutPoint{TxID: tx2.Hash()}})
+	_, errCode := pool.acceptTransaction(spending, nil, newTxEntry())
+	assert.Equal(t, errors.ErrDoubleSpend, errCode)
+	assert.Equal(t, 2, pool.GetTransactionCount())
+
+	replaced, errCode := pool.acceptTransaction(newTestTx(1000, 4000, 0, tx2.Inputs[0].Previous), nil, newTxEntry())
+	if assert.Equal(t, errors.Success, errCode) {
+		assert.Equal(t, []*core.Transaction{tx2}, replaced)
+	}
+	assert.NotNil(t, pool.GetTransaction(tx1.Hash()))
+}
+
+func TestTxPool_AppendChainedTransactions(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// each transaction spends the output of the previous one in pool
+	outPoint := l.depositOutPoint(0)
+	var chain []*core.Transaction
+	for i := 0; i < 3; i++ {
+		txn := l.spend(t, 1000, outPoint)
+		assert.Equal(t, errors.Success, pool.AppendToTxnPool(txn))
+		chain = append(chain, txn)
+		outPoint = core.OutPoint{TxID: txn.Hash()}
+	}
+	assert.Equal(t, 3, pool.GetTransactionCount())
+	// the fee is computed with the output of the parent in pool
+	assert.Equal(t, common.Fixed64(1000), chain[2].Fee)
+
+	// the output of a parent neither in pool nor in chain is not unspent
+	parent := l.spend(t, 1000, l.depositOutPoint(1))
+	child := l.spend(t, 1000, core.OutPoint{TxID: parent.Hash()})
+	assert.Equal(t, errors.ErrDoubleSpend, pool.AppendToTxnPool(child))
+}
+
+func TestTxPool_AcceptRemovedParent(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// the child of tx1 is checked with tx1 in pool
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(tx1))
+	child1 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	lookup := newParentLookup(pool)
+	assert.Equal(t, errors.Success, CheckChainedTransactionContext(child1, lookup))
+	assert.Equal(t, TxMap{tx1.Hash(): tx1}, lookup.parents)
+
+	// tx1 is evicted before the child is accepted
+	pool.Lock()
+	pool.evictTransaction(tx1)
+	pool.Unlock()
+	_, errCode := pool.acceptTransaction(child1, lookup.parents, newTxEntry())
+	assert.Equal(t, errors.ErrUnknownReferedTx, errCode)
+	assert.Equal(t, 0, pool.GetTransactionCount())
+	assert.Nil(t, pool.getInputUTXOList(child1.Inputs[0]))
+
+	// the child of tx2 is accepted if tx2 is removed once it is in a block
+	tx2 := l.spend(t, 1000, l.depositOutPoint(1))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(tx2))
+	child2 := l.spend(t, 1000, core.OutPoint{TxID: tx2.Hash()})
+	lookup = newParentLookup(pool)
+	assert.Equal(t, errors.Success, CheckChainedTransactionContext(child2, lookup))
+	block := &core.Block{
+		Header: core.Header{Height: 2},
+		Transactions: []*core.Transaction{
+			NewCoinBaseTransaction(&core.PayloadCoinBase{}, 2), tx2,
+		},
+	}
+	if !assert.NoError(t, l.store.persist(block)) {
+		return
+	}
+	pool.Lock()
+	pool.evictTransaction(tx2)
+	pool.Unlock()
+	_, errCode = pool.acceptTransaction(child2, lookup.parents, newTxEntry())
+	assert.Equal(t, errors.Success, errCode)
+	assert.NotNil(t, pool.GetTransaction(child2.Hash()))
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/events"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// txEntry records when a transaction is accepted to the pool.
+type txEntry struct {
+	time   time.Time
+	height uint32 // best height of the chain when accepted
+}
+
+// newTxEntry returns the entry of a transaction accepted now, the height is
+// zero if the chain is not initialized.
+func newTxEntry() *txEntry {
+	entry := &txEntry{time: time.Now()}
+	if DefaultLedger != nil && DefaultLedger.Blockchain != nil {
+		entry.height = DefaultLedger.Blockchain.GetBestHeight()
+	}
+	return entry
+}
+
+// GetTxAcceptance returns the time and the best height of the chain when the
+// transaction is accepted to the pool, ok is false if it is not in pool.
+func (pool *TxPool) GetTxAcceptance(hash Uint256) (acceptTime time.Time, height uint32, ok bool) {
+	pool.RLock()
+	defer pool.RUnlock()
+	entry, ok := pool.txnEntries[hash]
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	return entry.time, entry.height, true
+}
+
+// expireTransactions removes the transactions accepted longer than
+// config.Parameters.TxPoolAge ago, and the transactions spending their
+// outputs. An EventTransactionExpired is notified for each of them.
+func (pool *TxPool) expireTransactions(now time.Time) []*Transaction {
+	expired := pool.removeExpiredTransactions(now.Add(-config.Parameters.TxPoolAge()))
+	for _, txn := range expired {
+		log.Infof("[TxPool] transaction %s expired", txn.Hash().String())
+		DefaultLedger.Blockchain.BCEvents.Notify(events.EventTransactionExpired, txn)
+	}
+	return expired
+}
+
+// removeExpiredTransactions removes the transactions accepted before the
+// given time with their descendants, and returns the removed transactions.
+func (pool *TxPool) removeExpiredTransactions(before time.Time) []*Transaction {
+	pool.Lock()
+	defer pool.Unlock()
+
+	txs := make(map[Uint256]*Transaction)
+	for hash, entry := range pool.txnEntries {
+		if entry.time.Before(before) {
+			if txn, ok := pool.txnList[hash]; ok {
+				pool.collectSpenders(txn, txs)
+			}
+		}
+	}
+
+	expired := make([]*Transaction, 0, len(txs))
+	for _, txn := range txs {
+		pool.evictTransaction(txn)
+		expired = append(expired, txn)
+	}
+	return expired
+}
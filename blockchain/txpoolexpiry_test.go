@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_ExpireTransactions(t *testing.T) {
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1, tx3 is independent
+	tx1 := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{1}})
+	tx2 := newTestTx(0, 0, 0, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{3}})
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		addTestTx(pool, txn)
+	}
+	acceptTime, _, ok := pool.GetTxAcceptance(tx1.Hash())
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), acceptTime, time.Minute)
+
+	// nothing expires before the max age
+	assert.Empty(t, pool.removeExpiredTransactions(time.Now().Add(-config.Parameters.TxPoolAge())))
+	assert.Equal(t, 3, pool.GetTransactionCount())
+
+	// tx1 expires with tx2 spending its output
+	pool.txnEntries[tx1.Hash()].time = time.Now().Add(-config.Parameters.TxPoolAge() - time.Minute)
+	expired := pool.removeExpiredTransactions(time.Now().Add(-config.Parameters.TxPoolAge()))
+	assert.ElementsMatch(t, []*core.Transaction{tx1, tx2}, expired)
+	assert.Equal(t, 1, pool.GetTransactionCount())
+	assert.Nil(t, pool.getInputUTXOList(tx1.Inputs[0]))
+	assert.Nil(t, pool.getInputUTXOList(tx2.Inputs[0]))
+	_, _, ok = pool.GetTxAcceptance(tx1.Hash())
+	assert.False(t, ok)
+	assert.Equal(t, tx3.GetSize(), pool.GetTxPoolSize())
+}
+
+func TestTxPool_ExpireAppendedTransactions(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1, tx3 is independent
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := l.spend(t, 1000, l.depositOutPoint(1))
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		assert.Equal(t, errors.Success, pool.AppendToTxnPool(txn))
+	}
+
+	// tx1 expires with tx2, and the output it spent can be spent again
+	pool.txnEntries[tx1.Hash()].time = time.Now().Add(-config.Parameters.TxPoolAge() - time.Minute)
+	assert.ElementsMatch(t, []*core.Transaction{tx1, tx2}, pool.expireTransactions(time.Now()))
+	assert.Equal(t, 1, pool.GetTransactionCount())
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(tx1))
+}
@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	. "github.com/elastos/Elastos.ELA/core"
+	. "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// TxPoolSaveInterval is the interval the transaction pool is saved to file
+// at, besides the save on shutdown.
+const TxPoolSaveInterval = 10 * time.Minute
+
+// Transaction pool file is
+// magic(uint32) || count(var uint) || saved transactions
+// saved transaction is
+// accept time(unix nano, uint64) || accept height(uint32) || serialized transaction
+// the transactions are in dependency order, a transaction is after the
+// transactions in pool it spends from, so they can be appended to the pool
+// one by one when loaded. The acceptance is restored when loaded, so the
+// transactions still expire after the max age since they were accepted.
+
+// savedTx is a transaction saved in the pool file with its acceptance.
+type savedTx struct {
+	txn   *Transaction
+	entry txEntry
+}
+
+// SaveTxPool writes the transactions in pool to the file at path, it returns
+// the number of the saved transactions. The file is replaced atomically, so
+// a crash while saving keeps the previous file.
+func (pool *TxPool) SaveTxPool(path string) (int, error) {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+
+	txs := pool.getSortedTransactions()
+	w := bufio.NewWriter(f)
+	err = writeTxPool(w, config.Parameters.Magic, txs)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+	return len(txs), nil
+}
+
+// LoadTxPool appends the transactions saved in the file at path to the pool
+// with the time and the height they were accepted at, the transactions
+// expired or no longer valid are dropped. It returns the number of the
+// appended transactions, a missing file is not an error.
+func (pool *TxPool) LoadTxPool(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	txs, err := readTxPool(bufio.NewReader(f), config.Parameters.Magic)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	expireTime := time.Now().Add(-config.Parameters.TxPoolAge())
+	for _, saved := range txs {
+		txn := saved.txn
+		if saved.entry.time.Before(expireTime) {
+			log.Debugf("[TxPool] drop expired saved transaction %s", txn.Hash().String())
+			continue
+		}
+		entry := saved.entry
+		if errCode := pool.appendToTxnPool(txn, &entry); errCode != Success {
+			log.Debugf("[TxPool] drop saved transaction %s, %s",
+				txn.Hash().String(), errCode.Message())
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// getSortedTransactions returns the transactions in pool with their
+// acceptance in dependency order.
+func (pool *TxPool) getSortedTransactions() []savedTx {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	txs := make([]savedTx, 0, len(pool.txnList))
+	added := make(map[Uint256]struct{}, len(pool.txnList))
+	var add func(txn *Transaction)
+	add = func(txn *Transaction) {
+		txHash := txn.Hash()
+		if _, ok := added[txHash]; ok {
+			return
+		}
+		added[txHash] = struct{}{}
+		for _, parent := range pool.getParents(txn) {
+			add(parent)
+		}
+		txs = append(txs, savedTx{txn: txn, entry: *pool.txnEntries[txHash]})
+	}
+	for _, txn := range pool.txnList {
+		add(txn)
+	}
+	return txs
+}
+
+func writeTxPool(w io.Writer, magic uint32, txs []savedTx) error {
+	if err := WriteUint32(w, magic); err != nil {
+		return err
+	}
+	if err := WriteVarUint(w, uint64(len(txs))); err != nil {
+		return err
+	}
+	for _, saved := range txs {
+		if err := WriteUint64(w, uint64(saved.entry.time.UnixNano())); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, saved.entry.height); err != nil {
+			return err
+		}
+		if err := saved.txn.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTxPool(r io.Reader, magic uint32) ([]savedTx, error) {
+	fileMagic, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if fileMagic != magic {
+		return nil, fmt.Errorf("unmatched magic %d, expect %d", fileMagic, magic)
+	}
+	count, err := ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []savedTx
+	for i := uint64(0); i < count; i++ {
+		acceptTime, err := ReadUint64(r)
+		if err != nil {
+			return nil, fmt.Errorf("read accept time %d failed: %s", i, err)
+		}
+		height, err := ReadUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("read accept height %d failed: %s", i, err)
+		}
+		txn := new(Transaction)
+		if err := txn.Deserialize(r); err != nil {
+			return nil, fmt.Errorf("read transaction %d failed: %s", i, err)
+		}
+		txs = append(txs, savedTx{
+			txn:   txn,
+			entry: txEntry{time: time.Unix(0, int64(acceptTime)), height: height},
+		})
+	}
+	return txs, nil
+}
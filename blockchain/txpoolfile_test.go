@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_SaveTxPool(t *testing.T) {
+	pool := newTestTxPool()
+
+	// a chain of transactions and an independent transaction
+	chain := []*core.Transaction{newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{1}})}
+	for i := 0; i < 10; i++ {
+		chain = append(chain, newTestTx(0, 0, 0, core.OutPoint{TxID: chain[i].Hash()}))
+	}
+	for _, txn := range chain {
+		addTestTx(pool, txn)
+	}
+	addTestTx(pool, newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{2}}))
+
+	dir, err := ioutil.TempDir("", "txpool")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txpool.dat")
+
+	count, err := pool.SaveTxPool(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, len(chain)+1, count)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	txs, err := readTxPool(f, config.Parameters.Magic)
+	if !assert.NoError(t, err) || !assert.Len(t, txs, len(chain)+1) {
+		return
+	}
+
+	// a transaction is after the transactions it spends from
+	positions := make(map[common.Uint256]int)
+	for i, saved := range txs {
+		positions[saved.txn.Hash()] = i
+		assert.NotNil(t, pool.GetTransaction(saved.txn.Hash()))
+	}
+	for i := 1; i < len(chain); i++ {
+		assert.True(t, positions[chain[i-1].Hash()] < positions[chain[i].Hash()])
+	}
+
+	// a file of a different network is rejected
+	buf := new(bytes.Buffer)
+	assert.NoError(t, writeTxPool(buf, config.Parameters.Magic+1, txs))
+	_, err = readTxPool(buf, config.Parameters.Magic)
+	assert.Error(t, err)
+
+	// a missing file is not an error
+	count, err = pool.LoadTxPool(filepath.Join(dir, "missing.dat"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestTxPool_LoadTxPoolAcceptance(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1, tx3 is independent
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := l.spend(t, 1000, l.depositOutPoint(1))
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		assert.Equal(t, errors.Success, pool.AppendToTxnPool(txn))
+	}
+	acceptTime := time.Now().Add(-time.Hour)
+	pool.txnEntries[tx2.Hash()].time = acceptTime
+	pool.txnEntries[tx2.Hash()].height = 7
+	// tx3 has expired by the time the pool is loaded
+	pool.txnEntries[tx3.Hash()].time = time.Now().Add(-config.Parameters.TxPoolAge() - time.Minute)
+
+	dir, err := ioutil.TempDir("", "txpool")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txpool.dat")
+	count, err := pool.SaveTxPool(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// the acceptance is restored, so a restart does not reset the expiry
+	loaded := newTestTxPool()
+	count, err = loaded.LoadTxPool(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	loadedTime, height, ok := loaded.GetTxAcceptance(tx2.Hash())
+	if assert.True(t, ok) {
+		assert.True(t, acceptTime.Equal(loadedTime))
+		assert.Equal(t, uint32(7), height)
+	}
+	expectedTime, _, _ := pool.GetTxAcceptance(tx1.Hash())
+	loadedTime, _, ok = loaded.GetTxAcceptance(tx1.Hash())
+	if assert.True(t, ok) {
+		assert.True(t, expectedTime.Equal(loadedTime))
+	}
+	assert.Nil(t, loaded.GetTransaction(tx3.Hash()))
+}
+
+func TestTxPool_SaveAndLoadTxPool(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 3)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1, tx3 and tx4 are independent
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := l.spend(t, 1000, l.depositOutPoint(1))
+	tx4 := l.spend(t, 1000, l.depositOutPoint(2))
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3, tx4} {
+		assert.Equal(t, errors.Success, pool.AppendToTxnPool(txn))
+	}
+
+	dir, err := ioutil.TempDir("", "txpool")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txpool.dat")
+	count, err := pool.SaveTxPool(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// the signature of tx4 is broken in the file
+	data, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	txs, err := readTxPool(bytes.NewReader(data), config.Parameters.Magic)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, saved := range txs {
+		if saved.txn.Hash() == tx4.Hash() {
+			saved.txn.Programs[0].Parameter[10] ^= 0xff
+		}
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, writeTxPool(buf, config.Parameters.Magic, txs))
+	assert.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	// the output spent by tx3 is spent by another transaction in a block
+	// while the node is down
+	conflict := l.spend(t, 2000, l.depositOutPoint(1))
+	block := &core.Block{
+		Header: core.Header{Height: 2},
+		Transactions: []*core.Transaction{
+			NewCoinBaseTransaction(&core.PayloadCoinBase{}, 2), conflict,
+		},
+	}
+	if !assert.NoError(t, l.store.persist(block)) {
+		return
+	}
+
+	// the chain of tx1 and tx2 is appended again, the double spent tx3 and
+	// the invalid tx4 are dropped
+	loaded := newTestTxPool()
+	count, err = loaded.LoadTxPool(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.NotNil(t, loaded.GetTransaction(tx1.Hash()))
+	assert.NotNil(t, loaded.GetTransaction(tx2.Hash()))
+	assert.Nil(t, loaded.GetTransaction(tx3.Hash()))
+	assert.Nil(t, loaded.GetTransaction(tx4.Hash()))
+	if spender := loaded.getInputUTXOList(tx2.Inputs[0]); assert.NotNil(t, spender) {
+		assert.Equal(t, tx2.Hash(), spender.Hash())
+	}
+}
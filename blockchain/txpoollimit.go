@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// MinFeeIncrementPerKB is added to the highest fee per KB of the evicted
+	// transactions to get the raised min fee of the transaction pool.
+	MinFeeIncrementPerKB Fixed64 = 100
+
+	// MinFeeHalfLife is the time for the raised min fee to decay by half.
+	MinFeeHalfLife = time.Hour
+)
+
+// GetTxPoolSize returns the total size in bytes of the transactions in the
+// pool.
+func (pool *TxPool) GetTxPoolSize() int {
+	pool.RLock()
+	defer pool.RUnlock()
+	return pool.txnSize
+}
+
+// GetMinFeePerKB returns the min fee per KB of the transactions accepted by
+// the pool. It is raised when the pool is full and transactions are evicted,
+// then decays by half every MinFeeHalfLife.
+func (pool *TxPool) GetMinFeePerKB() Fixed64 {
+	pool.RLock()
+	defer pool.RUnlock()
+	return pool.minFeePerKBAt(time.Now())
+}
+
+func (pool *TxPool) minFeePerKBAt(now time.Time) Fixed64 {
+	if pool.minFeePerKB == 0 || !now.After(pool.minFeeTime) {
+		return pool.minFeePerKB
+	}
+	halfLives := float64(now.Sub(pool.minFeeTime)) / float64(MinFeeHalfLife)
+	fee := Fixed64(math.Floor(float64(pool.minFeePerKB)/math.Pow(2, halfLives) + 0.5))
+	// the min fee is dropped once it is low enough
+	if fee < MinFeeIncrementPerKB/2 {
+		return 0
+	}
+	return fee
+}
+
+// limitTxPoolSize evicts the transactions with the lowest fee per KB, and
+// the transactions spending their outputs, until the pool fits in the max
+// size, then the min fee is raised above the fees of the evicted
+// transactions. The transactions replaced by the given transaction are
+// counted as removed, and are not evicted for it. It returns if the given
+// transaction is evicted, the caller must hold the lock.
+func (pool *TxPool) limitTxPoolSize(txn *Transaction, replaced map[Uint256]*Transaction) bool {
+	maxSize := config.Parameters.TxPoolSize()
+	poolSize := func() int {
+		size := pool.txnSize
+		for hash, poolTx := range replaced {
+			if _, ok := pool.txnList[hash]; ok {
+				size -= poolTx.GetSize()
+			}
+		}
+		return size
+	}
+	if poolSize() <= maxSize {
+		return false
+	}
+
+	// the candidates are sorted once by fee per KB, the given transaction is
+	// evicted first among the transactions with the same fee
+	txHash := txn.Hash()
+	candidates := make([]*Transaction, 0, len(pool.txnList))
+	for hash, poolTx := range pool.txnList {
+		if _, ok := replaced[hash]; !ok {
+			candidates = append(candidates, poolTx)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].FeePerKB != candidates[j].FeePerKB {
+			return candidates[i].FeePerKB < candidates[j].FeePerKB
+		}
+		return candidates[i].Hash() == txHash
+	})
+
+	parents := pool.getParents(txn)
+	var evictedFee Fixed64
+	for _, lowest := range candidates {
+		if poolSize() <= maxSize {
+			break
+		}
+		// the spenders of the evicted transactions are already removed
+		if _, ok := pool.txnList[lowest.Hash()]; !ok {
+			continue
+		}
+		if lowest.FeePerKB > evictedFee {
+			evictedFee = lowest.FeePerKB
+		}
+		pool.evictTransaction(lowest)
+		// the replaced transactions are kept if the given one is evicted
+		if lowest.Hash() == txHash {
+			replaced = nil
+		}
+	}
+	// the given transaction may not spend the outputs it replaces yet, so it
+	// is evicted with its parents here
+	for _, parent := range parents {
+		if _, ok := pool.txnList[parent.Hash()]; !ok {
+			pool.evictTransaction(txn)
+			break
+		}
+	}
+
+	now := time.Now()
+	if minFee := evictedFee + MinFeeIncrementPerKB; minFee > pool.minFeePerKBAt(now) {
+		pool.minFeePerKB = minFee
+		pool.minFeeTime = now
+		log.Infof("[TxPool] transaction pool is full, min fee per KB is raised to %d", minFee)
+	}
+
+	_, ok := pool.txnList[txHash]
+	return !ok
+}
+
+// evictTransaction removes the transaction and the transactions spending its
+// outputs from the pool, the caller must hold the lock.
+func (pool *TxPool) evictTransaction(txn *Transaction) {
+	txHash := txn.Hash()
+	if _, ok := pool.txnList[txHash]; !ok {
+		return
+	}
+	log.Debugf("[TxPool] remove transaction %s with fee per KB %d", txHash.String(), txn.FeePerKB)
+
+	delete(pool.txnList, txHash)
+	delete(pool.txnEntries, txHash)
+	pool.txnSize -= txn.GetSize()
+	// the inputs and the sidechain transactions of a transaction not
+	// accepted yet are kept by the transactions it replaces
+	for _, input := range txn.Inputs {
+		key := input.ReferKey()
+		if spender, ok := pool.inputUTXOList[key]; ok && spender.Hash() == txHash {
+			delete(pool.inputUTXOList, key)
+		}
+	}
+	if txn.IsWithdrawFromSideChainTx() {
+		payload := txn.Payload.(*PayloadWithdrawFromSideChain)
+		for _, hash := range payload.SideChainTransactionHashes {
+			if poolTx, ok := pool.sidechainTxList[hash]; ok && poolTx.Hash() == txHash {
+				delete(pool.sidechainTxList, hash)
+			}
+		}
+	}
+
+	for index := range txn.Outputs {
+		input := Input{Previous: OutPoint{TxID: txHash, Index: uint16(index)}}
+		if spender, ok := pool.inputUTXOList[input.ReferKey()]; ok {
+			pool.evictTransaction(spender)
+		}
+	}
+}
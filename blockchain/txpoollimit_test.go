@@ -0,0 +1,251 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/log"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTxPool() *TxPool {
+	log.Init(
+		config.Parameters.PrintLevel,
+		config.Parameters.MaxPerLogSize,
+		config.Parameters.MaxLogsSize,
+	)
+	pool := new(TxPool)
+	pool.Init()
+	return pool
+}
+
+// newTestTx returns a transaction spending the out points with one output,
+// the fee and the fee per KB are set as if it is checked by the pool, and
+// the inputs have the given sequence.
+func newTestTx(fee, feePerKB common.Fixed64, sequence uint32, outPoints ...core.OutPoint) *core.Transaction {
+	txn := newSpendTransaction(outPoints...)
+	for _, input := range txn.Inputs {
+		input.Sequence = sequence
+	}
+	txn.Outputs = []*core.Output{{Value: 1}}
+	txn.Fee = fee
+	txn.FeePerKB = feePerKB
+	return txn
+}
+
+// addTestTx adds the transaction to the pool without checking it.
+func addTestTx(pool *TxPool, txn *core.Transaction) {
+	pool.addToTxList(txn)
+	for _, input := range txn.Inputs {
+		pool.addInputUTXOList(txn, input)
+	}
+	if txn.IsWithdrawFromSideChainTx() {
+		pool.addSidechainTx(txn)
+	}
+}
+
+// poolTestLedger is a chain of the genesis block and a block depositing
+// outputs to an account, the transactions spending them pass the checks of
+// AppendToTxnPool.
+type poolTestLedger struct {
+	store   *ChainStore
+	account *account
+	deposit *core.Transaction
+	// values are the values of the outputs of the account
+	values map[core.OutPoint]common.Fixed64
+}
+
+// newPoolTestLedger initializes DefaultLedger with a chain depositing the
+// given number of outputs of 10 ELA to an account. The returned function
+// closes the store and restores DefaultLedger.
+func newPoolTestLedger(t *testing.T, outputs int) (*poolTestLedger, func()) {
+	ledger := DefaultLedger
+	store, _ := newGenesisTestStore(t)
+	restore := func() {
+		store.Close()
+		DefaultLedger = ledger
+	}
+	if err := Init(store); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+
+	l := &poolTestLedger{
+		store:   store,
+		account: newAccount(t),
+		deposit: &core.Transaction{
+			TxType:     core.TransferAsset,
+			Payload:    &core.PayloadTransferAsset{},
+			Attributes: []*core.Attribute{},
+		},
+		values: make(map[core.OutPoint]common.Fixed64),
+	}
+	for i := 0; i < outputs; i++ {
+		l.deposit.Outputs = append(l.deposit.Outputs, &core.Output{
+			AssetID:     DefaultLedger.Blockchain.AssetID,
+			Value:       10 * common.Fixed64(ELA),
+			ProgramHash: *l.account.ProgramHash(),
+		})
+		l.values[core.OutPoint{TxID: l.deposit.Hash(), Index: uint16(i)}] = 10 * common.Fixed64(ELA)
+	}
+	block := &core.Block{
+		Header: core.Header{Height: 1, Previous: DefaultLedger.Blockchain.GenesisHash},
+		Transactions: []*core.Transaction{
+			NewCoinBaseTransaction(&core.PayloadCoinBase{}, 1), l.deposit,
+		},
+	}
+	if err := store.persist(block); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	return l, restore
+}
+
+// depositOutPoint returns the out point of the deposit output of the index.
+func (l *poolTestLedger) depositOutPoint(index int) core.OutPoint {
+	return core.OutPoint{TxID: l.deposit.Hash(), Index: uint16(index)}
+}
+
+// spend returns a transaction spending the outputs of the account to an
+// output of the account and paying the fee, signed by the account.
+func (l *poolTestLedger) spend(t *testing.T, fee common.Fixed64, outPoints ...core.OutPoint) *core.Transaction {
+	txn := newSpendTransaction(outPoints...)
+	txn.Attributes = []*core.Attribute{}
+	var value common.Fixed64
+	for _, outPoint := range outPoints {
+		value += l.values[outPoint]
+	}
+	txn.Outputs = []*core.Output{{
+		AssetID:     DefaultLedger.Blockchain.AssetID,
+		Value:       value - fee,
+		ProgramHash: *l.account.ProgramHash(),
+	}}
+	l.sign(t, txn)
+	return txn
+}
+
+// sign signs the transaction by the account, it is called again after the
+// transaction is changed.
+func (l *poolTestLedger) sign(t *testing.T, txn *core.Transaction) {
+	signature, err := l.account.Sign(getData(txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn.Programs = []*core.Program{{Code: l.account.RedeemScript(), Parameter: signature}}
+	for i, output := range txn.Outputs {
+		l.values[core.OutPoint{TxID: txn.Hash(), Index: uint16(i)}] = output.Value
+	}
+}
+
+func TestTxPool_LimitTxPoolSize(t *testing.T) {
+	pool := newTestTxPool()
+
+	maxSize := config.Parameters.MaxTxPoolSize
+	defer func() { config.Parameters.MaxTxPoolSize = maxSize }()
+
+	newTx := func(feePerKB common.Fixed64, outPoint core.OutPoint) *core.Transaction {
+		return newTestTx(0, feePerKB, 0, outPoint)
+	}
+
+	// tx2 spends the output of the low fee tx1
+	tx1 := newTx(200, core.OutPoint{TxID: common.Uint256{1}})
+	tx2 := newTx(5000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := newTx(1000, core.OutPoint{TxID: common.Uint256{3}})
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		addTestTx(pool, txn)
+	}
+	size := tx1.GetSize() + tx2.GetSize() + tx3.GetSize()
+	assert.Equal(t, size, pool.GetTxPoolSize())
+
+	// the pool is not full
+	config.Parameters.MaxTxPoolSize = size
+	assert.False(t, pool.limitTxPoolSize(tx3, nil))
+	assert.Equal(t, common.Fixed64(0), pool.GetMinFeePerKB())
+
+	// tx1 has the lowest fee, it is evicted with tx2 spending its output
+	tx4 := newTx(500, core.OutPoint{TxID: common.Uint256{4}})
+	addTestTx(pool, tx4)
+	assert.False(t, pool.limitTxPoolSize(tx4, nil))
+	assert.Equal(t, 2, pool.GetTransactionCount())
+	assert.Nil(t, pool.GetTransaction(tx1.Hash()))
+	assert.Nil(t, pool.GetTransaction(tx2.Hash()))
+	assert.Equal(t, tx3.GetSize()+tx4.GetSize(), pool.GetTxPoolSize())
+	for _, input := range append(tx1.Inputs, tx2.Inputs...) {
+		assert.Nil(t, pool.getInputUTXOList(input))
+	}
+	assert.Equal(t, 200+MinFeeIncrementPerKB, pool.minFeePerKBAt(pool.minFeeTime))
+
+	// a new transaction with the lowest fee is evicted itself
+	config.Parameters.MaxTxPoolSize = tx3.GetSize() + tx4.GetSize()
+	tx5 := newTx(400, core.OutPoint{TxID: common.Uint256{5}})
+	addTestTx(pool, tx5)
+	assert.True(t, pool.limitTxPoolSize(tx5, nil))
+	assert.Equal(t, 2, pool.GetTransactionCount())
+	assert.Nil(t, pool.getInputUTXOList(tx5.Inputs[0]))
+	assert.Equal(t, 400+MinFeeIncrementPerKB, pool.minFeePerKBAt(pool.minFeeTime))
+
+	// the min fee decays by half every half life and drops to zero
+	now := pool.minFeeTime
+	assert.Equal(t, (400+MinFeeIncrementPerKB)/2, pool.minFeePerKBAt(now.Add(MinFeeHalfLife)))
+	assert.Equal(t, common.Fixed64(0), pool.minFeePerKBAt(now.Add(10*MinFeeHalfLife)))
+	assert.Equal(t, 400+MinFeeIncrementPerKB, pool.minFeePerKBAt(now.Add(-time.Minute)))
+}
+
+func TestTxPool_LimitTxPoolSizeOrder(t *testing.T) {
+	pool := newTestTxPool()
+
+	maxSize := config.Parameters.MaxTxPoolSize
+	defer func() { config.Parameters.MaxTxPoolSize = maxSize }()
+
+	var txs []*core.Transaction
+	for i, feePerKB := range []common.Fixed64{700, 300, 900, 100, 500, 300} {
+		txn := newSpendTransaction(core.OutPoint{TxID: common.Uint256{byte(i + 1)}})
+		txn.FeePerKB = feePerKB
+		pool.addToTxList(txn)
+		txs = append(txs, txn)
+	}
+
+	// the three transactions with the lowest fees are evicted in one call,
+	// the given transaction goes first among the ones with the same fee
+	config.Parameters.MaxTxPoolSize = pool.GetTxPoolSize() - 2*txs[0].GetSize() - 1
+	assert.True(t, pool.limitTxPoolSize(txs[5], nil))
+	assert.Equal(t, 3, pool.GetTransactionCount())
+	for i, txn := range txs {
+		evicted := i == 1 || i == 3 || i == 5
+		assert.Equal(t, evicted, pool.GetTransaction(txn.Hash()) == nil)
+	}
+	assert.Equal(t, 300+MinFeeIncrementPerKB, pool.minFeePerKBAt(pool.minFeeTime))
+}
+
+func TestTxPool_AppendToFullPool(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 3)
+	defer restore()
+	pool := newTestTxPool()
+
+	maxSize := config.Parameters.MaxTxPoolSize
+	defer func() { config.Parameters.MaxTxPoolSize = maxSize }()
+
+	low := l.spend(t, 1000, l.depositOutPoint(0))
+	mid := l.spend(t, 2000, l.depositOutPoint(1))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(low))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(mid))
+
+	// the pool is full, the transaction with the lowest fee is evicted for
+	// a transaction paying a higher fee
+	config.Parameters.MaxTxPoolSize = pool.GetTxPoolSize()
+	high := l.spend(t, 3000, l.depositOutPoint(2))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(high))
+	assert.Equal(t, 2, pool.GetTransactionCount())
+	assert.Nil(t, pool.GetTransaction(low.Hash()))
+	assert.NotNil(t, pool.GetTransaction(mid.Hash()))
+	assert.NotNil(t, pool.GetTransaction(high.Hash()))
+
+	// the evicted transaction pays less than the raised min fee
+	assert.True(t, pool.GetMinFeePerKB() > low.FeePerKB)
+	assert.Equal(t, errors.ErrInsufficientFee, pool.AppendToTxnPool(low))
+}
@@ -0,0 +1,293 @@
+package blockchain
+
+import (
+	"time"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	. "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// MaxOrphanTransactions is the max number of the orphan transactions
+	// kept by the pool.
+	MaxOrphanTransactions = 100
+
+	// MaxOrphansPerPeer is the max number of the orphan transactions kept
+	// from one peer.
+	MaxOrphansPerPeer = 10
+
+	// MaxOrphanTxSize is the max size of an orphan transaction, the larger
+	// orphans are rejected to limit the memory used by the orphans.
+	MaxOrphanTxSize = 100000
+
+	// OrphanTTL is how long an orphan transaction is kept waiting for its
+	// parents.
+	OrphanTTL = 15 * time.Minute
+
+	// orphanExpireScanInterval is the min interval between two scans of the
+	// expired orphans.
+	orphanExpireScanInterval = 5 * time.Minute
+)
+
+// orphanTx is a transaction spending the outputs of the transactions neither
+// in pool nor in store, it waits for its parents to arrive.
+type orphanTx struct {
+	tx         *Transaction
+	peer       uint64
+	expiration time.Time
+}
+
+// ProcessTransaction appends the transaction to the pool, and the orphan
+// transactions it is the parent of. If allowOrphan is true, a transaction
+// whose parents are not arrived is kept as an orphan of the given peer
+// instead of rejected. It returns the transactions appended to the pool, it
+// is empty if the transaction is kept as an orphan.
+func (pool *TxPool) ProcessTransaction(txn *Transaction, allowOrphan bool, peer uint64) ([]*Transaction, ErrCode) {
+	if pool.IsOrphanInPool(txn.Hash()) {
+		return nil, ErrTransactionDuplicate
+	}
+
+	if !txn.IsCoinBaseTx() && pool.hasMissingParents(txn) {
+		if !allowOrphan {
+			log.Warn("[TxPool] transaction spends unknown outputs", txn.Hash().String())
+			return nil, ErrUnknownReferedTx
+		}
+		nextHeight := DefaultLedger.Blockchain.GetBestHeight() + 1
+		if errCode := CheckTransactionSanity(CheckTxOut, txn, nextHeight); errCode != Success {
+			log.Warn("[TxPool CheckTransactionSanity] failed", txn.Hash().String())
+			return nil, errCode
+		}
+		return nil, pool.maybeAddOrphan(txn, peer)
+	}
+
+	if errCode := pool.AppendToTxnPool(txn); errCode != Success {
+		return nil, errCode
+	}
+	return append([]*Transaction{txn}, pool.ProcessOrphans(txn)...), Success
+}
+
+// ProcessOrphans appends the orphan transactions spending the outputs of the
+// given transaction to the pool, and the orphans spending the outputs of
+// them recursively. The orphans still missing other parents are kept, the
+// invalid ones are removed with the orphans spending their outputs. It
+// returns the transactions appended to the pool.
+func (pool *TxPool) ProcessOrphans(txn *Transaction) []*Transaction {
+	var accepted []*Transaction
+	parents := []*Transaction{txn}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+
+		for _, orphan := range pool.getOrphanRedeemers(parent) {
+			if pool.hasMissingParents(orphan) {
+				continue
+			}
+			pool.RemoveOrphan(orphan, false)
+			if errCode := pool.AppendToTxnPool(orphan); errCode != Success {
+				log.Debugf("[TxPool] orphan transaction %s is rejected, %s",
+					orphan.Hash().String(), errCode.Message())
+				// the orphan is removed already, remove its redeemers
+				for _, redeemer := range pool.getOrphanRedeemers(orphan) {
+					pool.RemoveOrphan(redeemer, true)
+				}
+				continue
+			}
+			log.Debugf("[TxPool] orphan transaction %s is accepted", orphan.Hash().String())
+			accepted = append(accepted, orphan)
+			parents = append(parents, orphan)
+		}
+	}
+	return accepted
+}
+
+// IsOrphanInPool returns if the transaction is an orphan in pool.
+func (pool *TxPool) IsOrphanInPool(hash Uint256) bool {
+	pool.RLock()
+	defer pool.RUnlock()
+	_, ok := pool.orphans[hash]
+	return ok
+}
+
+// GetOrphanCount returns the number of the orphan transactions.
+func (pool *TxPool) GetOrphanCount() int {
+	pool.RLock()
+	defer pool.RUnlock()
+	return len(pool.orphans)
+}
+
+// RemoveOrphan removes the orphan transaction, and the orphans spending its
+// outputs if removeRedeemers is true.
+func (pool *TxPool) RemoveOrphan(txn *Transaction, removeRedeemers bool) {
+	pool.Lock()
+	defer pool.Unlock()
+	pool.removeOrphan(txn, removeRedeemers)
+}
+
+// RemoveOrphansByPeer removes the orphan transactions of the peer, it
+// returns the number of the removed orphans.
+func (pool *TxPool) RemoveOrphansByPeer(peer uint64) int {
+	pool.Lock()
+	defer pool.Unlock()
+
+	count := len(pool.orphans)
+	for _, orphan := range pool.orphans {
+		if orphan.peer == peer {
+			pool.removeOrphan(orphan.tx, true)
+		}
+	}
+	return count - len(pool.orphans)
+}
+
+// hasMissingParents returns if any input of the transaction spends an output
+// of a transaction neither in pool nor in store.
+func (pool *TxPool) hasMissingParents(txn *Transaction) bool {
+	for _, input := range txn.Inputs {
+		txId := input.Previous.TxID
+		if pool.GetTransaction(txId) != nil {
+			continue
+		}
+		if _, _, err := DefaultLedger.Store.GetTransaction(txId); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeAddOrphan keeps the transaction as an orphan of the peer, an orphan is
+// randomly evicted if there are too many orphans.
+func (pool *TxPool) maybeAddOrphan(txn *Transaction, peer uint64) ErrCode {
+	if size := txn.GetSize(); size > MaxOrphanTxSize {
+		log.Warnf("[TxPool] orphan transaction %s size %d is larger than %d",
+			txn.Hash().String(), size, MaxOrphanTxSize)
+		return ErrTransactionSize
+	}
+
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.expireOrphans(time.Now())
+
+	count := 0
+	for _, orphan := range pool.orphans {
+		if orphan.peer == peer {
+			count++
+		}
+	}
+	if count >= MaxOrphansPerPeer {
+		log.Warnf("[TxPool] peer 0x%x has %d orphan transactions, no more is allowed", peer, count)
+		return ErrOrphanLimit
+	}
+
+	if len(pool.orphans) >= MaxOrphanTransactions {
+		// the map iteration order is random
+		for _, orphan := range pool.orphans {
+			pool.removeOrphan(orphan.tx, false)
+			break
+		}
+	}
+
+	txHash := txn.Hash()
+	pool.orphans[txHash] = &orphanTx{
+		tx:         txn,
+		peer:       peer,
+		expiration: time.Now().Add(OrphanTTL),
+	}
+	for _, input := range txn.Inputs {
+		key := input.ReferKey()
+		if _, ok := pool.orphansByPrev[key]; !ok {
+			pool.orphansByPrev[key] = make(map[Uint256]*Transaction)
+		}
+		pool.orphansByPrev[key][txHash] = txn
+	}
+	log.Debugf("[TxPool] keep orphan transaction %s, total %d", txHash.String(), len(pool.orphans))
+	return Success
+}
+
+// getOrphanRedeemers returns the orphan transactions spending the outputs of
+// the given transaction.
+func (pool *TxPool) getOrphanRedeemers(txn *Transaction) []*Transaction {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	var redeemers []*Transaction
+	seen := make(map[Uint256]struct{})
+	txHash := txn.Hash()
+	for index := range txn.Outputs {
+		input := Input{Previous: OutPoint{TxID: txHash, Index: uint16(index)}}
+		for hash, orphan := range pool.orphansByPrev[input.ReferKey()] {
+			if _, ok := seen[hash]; ok {
+				continue
+			}
+			seen[hash] = struct{}{}
+			redeemers = append(redeemers, orphan)
+		}
+	}
+	return redeemers
+}
+
+// cleanOrphans removes the orphan transactions double spending the inputs of
+// the block transactions, and appends the orphans spending their outputs to
+// the pool.
+func (pool *TxPool) cleanOrphans(blockTxs []*Transaction) {
+	pool.Lock()
+	for _, blockTx := range blockTxs {
+		for _, input := range blockTx.Inputs {
+			for _, orphan := range pool.orphansByPrev[input.ReferKey()] {
+				pool.removeOrphan(orphan, true)
+			}
+		}
+	}
+	pool.expireOrphans(time.Now())
+	pool.Unlock()
+
+	for _, blockTx := range blockTxs {
+		pool.ProcessOrphans(blockTx)
+	}
+}
+
+// expireOrphans removes the expired orphan transactions, it scans the orphans
+// at most once per orphanExpireScanInterval. The caller must hold the lock.
+func (pool *TxPool) expireOrphans(now time.Time) {
+	if now.Before(pool.nextOrphanScan) {
+		return
+	}
+	for _, orphan := range pool.orphans {
+		if now.After(orphan.expiration) {
+			log.Debugf("[TxPool] orphan transaction %s expired", orphan.tx.Hash().String())
+			pool.removeOrphan(orphan.tx, true)
+		}
+	}
+	pool.nextOrphanScan = now.Add(orphanExpireScanInterval)
+}
+
+// removeOrphan removes the orphan transaction, and the orphans spending its
+// outputs if removeRedeemers is true. The caller must hold the lock.
+func (pool *TxPool) removeOrphan(txn *Transaction, removeRedeemers bool) {
+	txHash := txn.Hash()
+	if _, ok := pool.orphans[txHash]; !ok {
+		return
+	}
+	delete(pool.orphans, txHash)
+
+	for _, input := range txn.Inputs {
+		key := input.ReferKey()
+		if orphans, ok := pool.orphansByPrev[key]; ok {
+			delete(orphans, txHash)
+			if len(orphans) == 0 {
+				delete(pool.orphansByPrev, key)
+			}
+		}
+	}
+
+	if removeRedeemers {
+		for index := range txn.Outputs {
+			input := Input{Previous: OutPoint{TxID: txHash, Index: uint16(index)}}
+			for _, orphan := range pool.orphansByPrev[input.ReferKey()] {
+				pool.removeOrphan(orphan, true)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_Orphans(t *testing.T) {
+	pool := newTestTxPool()
+
+	// orphan2 spends the output of orphan1
+	parent := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{1}})
+	orphan1 := newTestTx(0, 0, 0, core.OutPoint{TxID: parent.Hash()})
+	orphan2 := newTestTx(0, 0, 0, core.OutPoint{TxID: orphan1.Hash()})
+	assert.Equal(t, errors.Success, pool.maybeAddOrphan(orphan1, 1))
+	assert.Equal(t, errors.Success, pool.maybeAddOrphan(orphan2, 1))
+	assert.True(t, pool.IsOrphanInPool(orphan1.Hash()))
+	assert.Equal(t, []*core.Transaction{orphan1}, pool.getOrphanRedeemers(parent))
+	assert.Equal(t, []*core.Transaction{orphan2}, pool.getOrphanRedeemers(orphan1))
+
+	// removing an orphan with its redeemers
+	pool.RemoveOrphan(orphan1, true)
+	assert.Equal(t, 0, pool.GetOrphanCount())
+	assert.Empty(t, pool.orphansByPrev)
+
+	// the orphans of a peer are limited
+	for i := 0; i < MaxOrphansPerPeer; i++ {
+		orphan := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{2, byte(i)}})
+		assert.Equal(t, errors.Success, pool.maybeAddOrphan(orphan, 2))
+	}
+	assert.Equal(t, errors.ErrOrphanLimit, pool.maybeAddOrphan(newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{3}}), 2))
+	assert.Equal(t, MaxOrphansPerPeer, pool.RemoveOrphansByPeer(2))
+	assert.Equal(t, 0, pool.GetOrphanCount())
+
+	// the orphans are evicted when there are too many
+	for i := 0; i < MaxOrphanTransactions+1; i++ {
+		orphan := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{4, byte(i)}})
+		assert.Equal(t, errors.Success, pool.maybeAddOrphan(orphan, uint64(i)))
+	}
+	assert.Equal(t, MaxOrphanTransactions, pool.GetOrphanCount())
+
+	// a large orphan is rejected
+	large := newTestTx(0, 0, 0, core.OutPoint{TxID: common.Uint256{5}})
+	large.Attributes = []*core.Attribute{{Usage: core.Memo, Data: make([]byte, MaxOrphanTxSize)}}
+	assert.Equal(t, errors.ErrTransactionSize, pool.maybeAddOrphan(large, 5))
+
+	// the orphans expire after OrphanTTL
+	pool.Lock()
+	pool.expireOrphans(time.Now().Add(OrphanTTL + time.Minute))
+	pool.Unlock()
+	assert.Equal(t, 0, pool.GetOrphanCount())
+}
+
+func TestTxPool_ProcessOrphans(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1 and tx3 spends the output of tx2, the
+	// invalid tx4 spends the output of tx1 and tx5 spends the output of tx4,
+	// tx6 also spends an output not arrived
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := l.spend(t, 1000, core.OutPoint{TxID: tx2.Hash()})
+	tx4 := l.spend(t, 2000, core.OutPoint{TxID: tx1.Hash()})
+	tx4.Programs[0].Parameter[10] ^= 0xff
+	tx5 := l.spend(t, 1000, core.OutPoint{TxID: tx4.Hash()})
+	tx6 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()},
+		core.OutPoint{TxID: common.Uint256{1}})
+
+	// the orphans are rejected unless they are allowed
+	_, errCode := pool.ProcessTransaction(tx3, false, 1)
+	assert.Equal(t, errors.ErrUnknownReferedTx, errCode)
+	for _, orphan := range []*core.Transaction{tx3, tx2, tx4, tx5, tx6} {
+		accepted, errCode := pool.ProcessTransaction(orphan, true, 1)
+		assert.Equal(t, errors.Success, errCode)
+		assert.Empty(t, accepted)
+	}
+	assert.Equal(t, 5, pool.GetOrphanCount())
+	_, errCode = pool.ProcessTransaction(tx2, true, 1)
+	assert.Equal(t, errors.ErrTransactionDuplicate, errCode)
+
+	// the chain of the orphans is appended with their parent, the invalid
+	// tx4 is removed with tx5, tx6 is kept waiting for its other parent
+	accepted, errCode := pool.ProcessTransaction(tx1, false, 1)
+	assert.Equal(t, errors.Success, errCode)
+	assert.Equal(t, []*core.Transaction{tx1, tx2, tx3}, accepted)
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		assert.NotNil(t, pool.GetTransaction(txn.Hash()))
+	}
+	for _, txn := range []*core.Transaction{tx4, tx5, tx6} {
+		assert.Nil(t, pool.GetTransaction(txn.Hash()))
+	}
+	assert.Equal(t, 1, pool.GetOrphanCount())
+	assert.True(t, pool.IsOrphanInPool(tx6.Hash()))
+}
+
+func TestTxPool_CleanOrphans(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 2)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of tx1 which is not relayed to the pool, tx3
+	// spends the output of tx2, tx4 double spends the input of tx5
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := l.spend(t, 1000, core.OutPoint{TxID: tx2.Hash()})
+	tx4 := l.spend(t, 1000, l.depositOutPoint(1), core.OutPoint{TxID: common.Uint256{1}})
+	tx5 := l.spend(t, 2000, l.depositOutPoint(1))
+	for _, orphan := range []*core.Transaction{tx2, tx3, tx4} {
+		_, errCode := pool.ProcessTransaction(orphan, true, 1)
+		assert.Equal(t, errors.Success, errCode)
+	}
+	assert.Equal(t, 3, pool.GetOrphanCount())
+
+	// tx1 and tx5 are connected in a block
+	block := &core.Block{
+		Header: core.Header{Height: 2},
+		Transactions: []*core.Transaction{
+			NewCoinBaseTransaction(&core.PayloadCoinBase{}, 2), tx1, tx5,
+		},
+	}
+	if !assert.NoError(t, l.store.persist(block)) {
+		return
+	}
+
+	// the orphans of tx1 are appended, the double spending tx4 is removed
+	pool.cleanOrphans(block.Transactions)
+	assert.Equal(t, 0, pool.GetOrphanCount())
+	assert.NotNil(t, pool.GetTransaction(tx2.Hash()))
+	assert.NotNil(t, pool.GetTransaction(tx3.Hash()))
+	assert.Nil(t, pool.GetTransaction(tx4.Hash()))
+}
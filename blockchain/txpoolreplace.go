@@ -0,0 +1,214 @@
+package blockchain
+
+import (
+	"fmt"
+	"math"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	. "github.com/elastos/Elastos.ELA/errors"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// MaxReplaceableSequence is the max input sequence which signals the
+	// transaction can be replaced in the pool by a transaction paying a
+	// higher fee. The inputs spending locked outputs use math.MaxUint32 - 1,
+	// so they never signal replacement.
+	MaxReplaceableSequence = math.MaxUint32 - 2
+
+	// MaxReplacedTransactions is the max number of pooled transactions
+	// replaced by one transaction, including the transactions spending their
+	// outputs.
+	MaxReplacedTransactions = 100
+)
+
+// TxReplacement is the value of EventTransactionReplaced, the replaced
+// transactions are removed from the pool for the replacement transaction.
+type TxReplacement struct {
+	Transaction *Transaction
+	Replaced    []*Transaction
+}
+
+// IsReplaceable returns if the transaction opts in to be replaced in the
+// pool, by any input with a sequence not higher than MaxReplaceableSequence.
+func IsReplaceable(txn *Transaction) bool {
+	for _, input := range txn.Inputs {
+		if input.Sequence <= MaxReplaceableSequence {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptTransaction appends the transaction to the pool, in place of the
+// pooled transactions spending the same outputs or withdrawing the same
+// sidechain transactions, and the transactions spending their outputs. The
+// given transaction must pay a strictly higher fee than the total fee of the
+// replaced transactions, and a strictly higher fee per KB than each of them.
+// Nothing is replaced if any of the conflicting transactions is not
+// replaceable, the transaction is rejected as a double spend then. A
+// conflicting transaction is replaceable if it or any of its unconfirmed
+// ancestors opts in.
+//
+// The conflicts are checked, the transaction is appended and the size of the
+// pool is limited in one critical section. The replaced transactions are
+// removed only once the transaction is kept in the pool, so they stay pooled
+// if it is rejected. The parents the transaction is checked with must be still
+// in pool or in store, they may be replaced or evicted after the check. The
+// transaction is accepted at the time and the height of the entry. It returns
+// the replaced transactions.
+func (pool *TxPool) acceptTransaction(txn *Transaction, parents TxMap, entry *txEntry) ([]*Transaction, ErrCode) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	txHash := txn.Hash()
+	if _, ok := pool.txnList[txHash]; ok {
+		// reject duplicated transaction
+		log.Debugf("Transaction duplicate %s", txHash.String())
+		return nil, ErrTransactionDuplicate
+	}
+	for hash := range parents {
+		if _, ok := pool.txnList[hash]; ok {
+			continue
+		}
+		// the parent is removed from pool once it is in a block
+		if _, _, err := DefaultLedger.Store.GetTransaction(hash); err != nil {
+			log.Warnf("[TxPool] parent %s of transaction %s is not in pool any more",
+				hash.String(), txHash.String())
+			return nil, ErrUnknownReferedTx
+		}
+	}
+	replaced, ruleErr := pool.getConflicts(txn)
+	if ruleErr != nil {
+		log.Warn(ruleErr)
+		return nil, ruleErr.Code
+	}
+
+	// the inputs and the sidechain transactions are not taken over from the
+	// replaced transactions until the transaction is kept
+	pool.txnList[txHash] = txn
+	pool.txnEntries[txHash] = entry
+	pool.txnSize += txn.GetSize()
+	//evict the transactions with the lowest fee if the pool is full
+	if evicted := pool.limitTxPoolSize(txn, replaced); evicted {
+		log.Warnf("[TxPool] transaction %s is evicted from the full pool", txHash.String())
+		return nil, ErrTxPoolFull
+	}
+
+	// the replaced transactions evicted with their parents are not reported
+	replacedTxs := make([]*Transaction, 0, len(replaced))
+	for hash, poolTx := range replaced {
+		if _, ok := pool.txnList[hash]; ok {
+			replacedTxs = append(replacedTxs, poolTx)
+		}
+	}
+	for _, poolTx := range replacedTxs {
+		log.Infof("[TxPool] transaction %s is replaced by %s", poolTx.Hash().String(), txHash.String())
+		pool.evictTransaction(poolTx)
+	}
+	for _, input := range txn.Inputs {
+		pool.inputUTXOList[input.ReferKey()] = txn
+	}
+	if txn.IsWithdrawFromSideChainTx() {
+		payload := txn.Payload.(*PayloadWithdrawFromSideChain)
+		for _, hash := range payload.SideChainTransactionHashes {
+			pool.sidechainTxList[hash] = txn
+		}
+	}
+	return replacedTxs, Success
+}
+
+// getReplaced returns the pooled transactions the given transaction replaces,
+// and an error if it can not replace them. The caller must hold the lock.
+func (pool *TxPool) getReplaced(txn *Transaction) (map[Uint256]*Transaction, error) {
+	txHash := txn.Hash()
+	conflicts := make(map[Uint256]*Transaction)
+	for _, input := range txn.Inputs {
+		if conflict, ok := pool.inputUTXOList[input.ReferKey()]; ok {
+			conflicts[conflict.Hash()] = conflict
+		}
+	}
+	if txn.IsWithdrawFromSideChainTx() {
+		payload := txn.Payload.(*PayloadWithdrawFromSideChain)
+		for _, hash := range payload.SideChainTransactionHashes {
+			if conflict, ok := pool.sidechainTxList[hash]; ok {
+				conflicts[conflict.Hash()] = conflict
+			}
+		}
+	}
+
+	replaced := make(map[Uint256]*Transaction)
+	for hash, conflict := range conflicts {
+		if !pool.isReplaceableInPool(conflict) {
+			log.Debugf("[TxPool] transaction %s in pool is not replaceable", hash.String())
+			return nil, nil
+		}
+		pool.collectSpenders(conflict, replaced)
+	}
+	if len(replaced) == 0 {
+		return nil, nil
+	}
+	if len(replaced) > MaxReplacedTransactions {
+		return nil, fmt.Errorf("transaction %s replaces %d transactions, more than %d",
+			txHash.String(), len(replaced), MaxReplacedTransactions)
+	}
+
+	for _, input := range txn.Inputs {
+		if _, ok := replaced[input.Previous.TxID]; ok {
+			return nil, fmt.Errorf("transaction %s spends the output of the replaced transaction %s",
+				txHash.String(), input.Previous.TxID.String())
+		}
+	}
+
+	var totalFee Fixed64
+	for hash, poolTx := range replaced {
+		if txn.FeePerKB <= poolTx.FeePerKB {
+			return nil, fmt.Errorf("transaction %s fee per KB %d is not higher than %d of the replaced transaction %s",
+				txHash.String(), txn.FeePerKB, poolTx.FeePerKB, hash.String())
+		}
+		totalFee += poolTx.Fee
+	}
+	if txn.Fee <= totalFee {
+		return nil, fmt.Errorf("transaction %s fee %d is not higher than %d of the replaced transactions",
+			txHash.String(), txn.Fee, totalFee)
+	}
+
+	return replaced, nil
+}
+
+// isReplaceableInPool returns if the transaction or any of its unconfirmed
+// ancestors is replaceable, the transaction inherits the replaceability from
+// its ancestors, because replacing them replaces it too. The caller must hold
+// the lock.
+func (pool *TxPool) isReplaceableInPool(txn *Transaction) bool {
+	if IsReplaceable(txn) {
+		return true
+	}
+	ancestors := make(map[Uint256]*Transaction)
+	pool.collectAncestors(txn, ancestors)
+	for _, ancestor := range ancestors {
+		if IsReplaceable(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSpenders adds the transaction and the pooled transactions spending
+// its outputs to the given map, the caller must hold the lock.
+func (pool *TxPool) collectSpenders(txn *Transaction, txs map[Uint256]*Transaction) {
+	txHash := txn.Hash()
+	if _, ok := txs[txHash]; ok {
+		return
+	}
+	txs[txHash] = txn
+
+	for index := range txn.Outputs {
+		input := Input{Previous: OutPoint{TxID: txHash, Index: uint16(index)}}
+		if spender, ok := pool.inputUTXOList[input.ReferKey()]; ok {
+			pool.collectSpenders(spender, txs)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/errors"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPool_AcceptReplacement(t *testing.T) {
+	pool := newTestTxPool()
+
+	// a transaction without replaceable inputs is not replaced
+	final := newTestTx(100, 400, math.MaxUint32, core.OutPoint{TxID: common.Uint256{1}})
+	assert.False(t, IsReplaceable(final))
+	addTestTx(pool, final)
+	replaced, errCode := pool.acceptTransaction(newTestTx(1000, 4000, 0, final.Inputs[0].Previous), nil, newTxEntry())
+	assert.Equal(t, errors.ErrDoubleSpend, errCode)
+	assert.Empty(t, replaced)
+	assert.NotNil(t, pool.GetTransaction(final.Hash()))
+	assert.Equal(t, final, pool.getInputUTXOList(final.Inputs[0]))
+
+	// tx2 spends the output of the replaceable tx1
+	tx1 := newTestTx(100, 400, MaxReplaceableSequence, core.OutPoint{TxID: common.Uint256{2}})
+	tx2 := newTestTx(200, 800, math.MaxUint32, core.OutPoint{TxID: tx1.Hash()})
+	assert.True(t, IsReplaceable(tx1))
+	addTestTx(pool, tx1)
+	addTestTx(pool, tx2)
+
+	// the fee must be higher than the total fee of tx1 and tx2
+	_, errCode = pool.acceptTransaction(newTestTx(300, 900, 0, tx1.Inputs[0].Previous), nil, newTxEntry())
+	assert.Equal(t, errors.ErrDoubleSpend, errCode)
+	// the fee per KB must be higher than each of tx1 and tx2
+	_, errCode = pool.acceptTransaction(newTestTx(1000, 800, 0, tx1.Inputs[0].Previous), nil, newTxEntry())
+	assert.Equal(t, errors.ErrDoubleSpend, errCode)
+	assert.Equal(t, 3, pool.GetTransactionCount())
+
+	replacement := newTestTx(301, 801, 0, tx1.Inputs[0].Previous)
+	replaced, errCode = pool.acceptTransaction(replacement, nil, newTxEntry())
+	if assert.Equal(t, errors.Success, errCode) {
+		assert.Len(t, replaced, 2)
+	}
+	assert.Nil(t, pool.GetTransaction(tx1.Hash()))
+	assert.Nil(t, pool.GetTransaction(tx2.Hash()))
+	assert.Equal(t, replacement, pool.GetTransaction(replacement.Hash()))
+	assert.Equal(t, replacement, pool.getInputUTXOList(tx1.Inputs[0]))
+	assert.Nil(t, pool.getInputUTXOList(tx2.Inputs[0]))
+	assert.Equal(t, final.GetSize()+replacement.GetSize(), pool.GetTxPoolSize())
+
+	// a withdraw transaction conflicts with the one withdrawing the same
+	// sidechain transaction
+	withdraw1 := newTestTx(100, 400, 0, core.OutPoint{TxID: common.Uint256{3}})
+	withdraw1.TxType = core.WithdrawFromSideChain
+	withdraw1.Payload = &core.PayloadWithdrawFromSideChain{
+		SideChainTransactionHashes: []common.Uint256{{0x12}},
+	}
+	addTestTx(pool, withdraw1)
+	withdraw2 := newTestTx(200, 800, 0, core.OutPoint{TxID: common.Uint256{4}})
+	withdraw2.TxType = core.WithdrawFromSideChain
+	withdraw2.Payload = withdraw1.Payload
+	replaced, errCode = pool.acceptTransaction(withdraw2, nil, newTxEntry())
+	if assert.Equal(t, errors.Success, errCode) {
+		assert.Equal(t, []*core.Transaction{withdraw1}, replaced)
+	}
+	assert.Nil(t, pool.getInputUTXOList(withdraw1.Inputs[0]))
+	assert.Equal(t, withdraw2, pool.sidechainTxList[common.Uint256{0x12}])
+
+	// the same transaction is a duplicate
+	_, errCode = pool.acceptTransaction(withdraw2, nil, newTxEntry())
+	assert.Equal(t, errors.ErrTransactionDuplicate, errCode)
+}
+
+func TestTxPool_ReplacementPoolFull(t *testing.T) {
+	pool := newTestTxPool()
+
+	maxSize := config.Parameters.MaxTxPoolSize
+	defer func() { config.Parameters.MaxTxPoolSize = maxSize }()
+
+	newTx := func(fee, feePerKB common.Fixed64, outputs int, outPoint core.OutPoint) *core.Transaction {
+		txn := newTestTx(fee, feePerKB, 0, outPoint)
+		for i := 1; i < outputs; i++ {
+			txn.Outputs = append(txn.Outputs, &core.Output{Value: 1})
+		}
+		return txn
+	}
+
+	// tx2 spends the output of tx1, tx3 pays a high fee
+	tx1 := newTx(100, 400, 1, core.OutPoint{TxID: common.Uint256{1}})
+	tx2 := newTx(100, 400, 1, core.OutPoint{TxID: tx1.Hash()})
+	tx3 := newTx(10000, 40000, 1, core.OutPoint{TxID: common.Uint256{3}})
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		addTestTx(pool, txn)
+	}
+	size := pool.GetTxPoolSize()
+	config.Parameters.MaxTxPoolSize = size
+
+	// the replacement does not fit in the space of the replaced
+	// transactions, it has the lowest fee left and is evicted, the
+	// originals are still pooled
+	larger := newTx(1000, 4000, 20, tx1.Inputs[0].Previous)
+	assert.True(t, larger.GetSize() > tx1.GetSize()+tx2.GetSize())
+	replaced, errCode := pool.acceptTransaction(larger, nil, newTxEntry())
+	assert.Equal(t, errors.ErrTxPoolFull, errCode)
+	assert.Empty(t, replaced)
+	assert.Nil(t, pool.GetTransaction(larger.Hash()))
+	for _, txn := range []*core.Transaction{tx1, tx2, tx3} {
+		assert.Equal(t, txn, pool.GetTransaction(txn.Hash()))
+		assert.Equal(t, txn, pool.getInputUTXOList(txn.Inputs[0]))
+	}
+	assert.Equal(t, size, pool.GetTxPoolSize())
+
+	// the replacement fits in the space of the replaced transactions
+	replacement := newTx(1000, 4000, 1, tx1.Inputs[0].Previous)
+	replacement.Outputs[0].Value = 2
+	replaced, errCode = pool.acceptTransaction(replacement, nil, newTxEntry())
+	if assert.Equal(t, errors.Success, errCode) {
+		assert.Len(t, replaced, 2)
+	}
+	assert.Equal(t, 2, pool.GetTransactionCount())
+	assert.Equal(t, replacement, pool.getInputUTXOList(tx1.Inputs[0]))
+	assert.Equal(t, tx3, pool.GetTransaction(tx3.Hash()))
+}
+
+func TestTxPool_AppendReplacement(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 1)
+	defer restore()
+	pool := newTestTxPool()
+
+	// tx2 spends the output of the replaceable tx1
+	tx1 := l.spend(t, 1000, l.depositOutPoint(0))
+	tx2 := l.spend(t, 1000, core.OutPoint{TxID: tx1.Hash()})
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(tx1))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(tx2))
+
+	// the replacement must pay more than both of them
+	low := l.spend(t, 1500, l.depositOutPoint(0))
+	assert.Equal(t, errors.ErrDoubleSpend, pool.AppendToTxnPool(low))
+	assert.Equal(t, 2, pool.GetTransactionCount())
+
+	replacement := l.spend(t, 3000, l.depositOutPoint(0))
+	assert.Equal(t, errors.Success, pool.AppendToTxnPool(replacement))
+	assert.Equal(t, 1, pool.GetTransactionCount())
+	assert.Nil(t, pool.GetTransaction(tx1.Hash()))
+	assert.Nil(t, pool.GetTransaction(tx2.Hash()))
+	assert.Equal(t, replacement, pool.getInputUTXOList(replacement.Inputs[0]))
+}
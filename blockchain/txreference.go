@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// TxLookup looks up the unconfirmed transactions whose outputs can be spent
+// before they are in store, they are the transactions in the transaction
+// pool, or the previous transactions of a block.
+type TxLookup interface {
+	GetTransaction(hash Uint256) *Transaction
+}
+
+// TxMap is a TxLookup of the given transactions.
+type TxMap map[Uint256]*Transaction
+
+func (m TxMap) GetTransaction(hash Uint256) *Transaction {
+	return m[hash]
+}
+
+// getTxReference returns the outputs referenced by the inputs of the
+// transaction, they are looked up in the unconfirmed transactions first and
+// then in store.
+func getTxReference(txn *Transaction, unconfirmed TxLookup) (map[*Input]*Output, error) {
+	if unconfirmed == nil {
+		return DefaultLedger.Store.GetTxReference(txn)
+	}
+	if txn.TxType == RegisterAsset {
+		return nil, nil
+	}
+
+	reference := make(map[*Input]*Output)
+	for _, input := range txn.Inputs {
+		referTxn := unconfirmed.GetTransaction(input.Previous.TxID)
+		if referTxn == nil {
+			var err error
+			referTxn, _, err = DefaultLedger.Store.GetTransaction(input.Previous.TxID)
+			if err != nil {
+				return nil, errors.New("GetTxReference failed, previous transaction not found")
+			}
+		}
+		index := input.Previous.Index
+		if int(index) >= len(referTxn.Outputs) {
+			return nil, errors.New("GetTxReference failed, refIdx out of range.")
+		}
+		reference[input] = referTxn.Outputs[index]
+	}
+	return reference, nil
+}
+
+// isDoubleSpend returns if any input of the transaction spends an output in
+// store which is not unspent. The inputs spending the outputs of the
+// unconfirmed transactions are checked by the transaction pool, or by the
+// sanity check of the block.
+func isDoubleSpend(txn *Transaction, unconfirmed TxLookup) bool {
+	if unconfirmed == nil {
+		return DefaultLedger.IsDoubleSpend(txn)
+	}
+
+	for _, input := range txn.Inputs {
+		if unconfirmed.GetTransaction(input.Previous.TxID) != nil {
+			continue
+		}
+		unspent, _ := DefaultLedger.Store.ContainsUnspent(input.Previous.TxID, input.Previous.Index)
+		if !unspent {
+			return true
+		}
+	}
+	return false
+}
@@ -15,123 +15,153 @@ import (
 	. "github.com/elastos/Elastos.ELA.Utility/crypto"
 )
 
-// CheckTransactionSanity verifys received single transaction
-func CheckTransactionSanity(version uint32, txn *Transaction) ErrCode {
+// CheckTransactionSanity verifys received single transaction for the block of
+// the height
+func CheckTransactionSanity(version uint32, txn *Transaction, blockHeight uint32) ErrCode {
+	if err := checkTransactionSanity(version, txn, blockHeight); err != nil {
+		log.Warn(err)
+		return err.Code
+	}
+	return Success
+}
+
+// checkTransactionSanity returns the sanity rule the transaction fails.
+func checkTransactionSanity(version uint32, txn *Transaction, blockHeight uint32) *RuleError {
 	if err := CheckTransactionSize(txn); err != nil {
-		log.Warn("[CheckTransactionSize],", err)
-		return ErrTransactionSize
+		return NewRuleError(ErrTransactionSize, "CheckTransactionSize", err)
 	}
 
 	if err := CheckTransactionInput(txn); err != nil {
-		log.Warn("[CheckTransactionInput],", err)
-		return ErrInvalidInput
+		return NewRuleError(ErrInvalidInput, "CheckTransactionInput", err)
 	}
 
-	if err := CheckTransactionOutput(version, txn); err != nil {
-		log.Warn("[CheckTransactionOutput],", err)
-		return ErrInvalidOutput
+	if err := CheckTransactionOutput(version, txn, blockHeight); err != nil {
+		return NewRuleError(ErrInvalidOutput, "CheckTransactionOutput", err)
 	}
 
 	if err := CheckAssetPrecision(txn); err != nil {
-		log.Warn("[CheckAssetPrecesion],", err)
-		return ErrAssetPrecision
+		return NewRuleError(ErrAssetPrecision, "CheckAssetPrecesion", err)
 	}
 
 	if err := CheckAttributeProgram(txn); err != nil {
-		log.Warn("[CheckAttributeProgram],", err)
-		return ErrAttributeProgram
+		return NewRuleError(ErrAttributeProgram, "CheckAttributeProgram", err)
 	}
 
 	if err := CheckTransactionPayload(txn); err != nil {
-		log.Warn("[CheckTransactionPayload],", err)
-		return ErrTransactionPayload
+		return NewRuleError(ErrTransactionPayload, "CheckTransactionPayload", err)
 	}
 
 	if err := CheckDuplicateSidechainTx(txn); err != nil {
-		log.Warn("[CheckDuplicateSidechainTx],", err)
-		return ErrSidechainTxDuplicate
+		return NewRuleError(ErrSidechainTxDuplicate, "CheckDuplicateSidechainTx", err)
 	}
 
 	// check iterms above for Coinbase transaction
 	if txn.IsCoinBaseTx() {
-		return Success
+		return nil
 	}
 
-	return Success
+	return nil
 }
 
 // CheckTransactionContext verifys a transaction with history transaction in ledger
 func CheckTransactionContext(txn *Transaction) ErrCode {
+	return checkTransactionContext(txn, nil, true)
+}
+
+// CheckChainedTransactionContext verifys a transaction which may spend the
+// outputs of the given unconfirmed transactions.
+func CheckChainedTransactionContext(txn *Transaction, unconfirmed TxLookup) ErrCode {
+	return checkTransactionContext(txn, unconfirmed, true)
+}
+
+func checkTransactionContext(txn *Transaction, unconfirmed TxLookup, checkSignature bool) ErrCode {
+	if err := checkTransactionContextRules(txn, unconfirmed, checkSignature); err != nil {
+		log.Warn(err)
+		return err.Code
+	}
+	return Success
+}
+
+// checkTransactionContextRules returns the context rule the transaction
+// fails, it is checked for the next block.
+func checkTransactionContextRules(txn *Transaction, unconfirmed TxLookup, checkSignature bool) *RuleError {
+	nextHeight := DefaultLedger.Blockchain.GetBestHeight() + 1
+	_, err := checkTransactionContextReferences(txn, unconfirmed, checkSignature, nextHeight)
+	return err
+}
+
+// checkTransactionContextReferences returns the outputs the transaction
+// spends and the context rule it fails in the block of the height, the
+// outputs are nil for a coinbase.
+func checkTransactionContextReferences(txn *Transaction, unconfirmed TxLookup,
+	checkSignature bool, blockHeight uint32) (map[*Input]*Output, *RuleError) {
 	// check if duplicated with transaction in ledger
 	if exist := DefaultLedger.Store.IsTxHashDuplicate(txn.Hash()); exist {
-		log.Warn("[CheckTransactionContext] duplicate transaction check failed.")
-		return ErrTransactionDuplicate
+		return nil, NewRuleError(ErrTransactionDuplicate, "CheckTransactionContext",
+			errors.New("duplicate transaction check failed"))
 	}
 
 	if txn.IsCoinBaseTx() {
-		return Success
+		return nil, nil
 	}
 
 	if txn.IsSideChainPowTx() {
 		arbitrtor, err := GetCurrentArbiter()
 		if err != nil {
-			return ErrSideChainPowConsensus
+			return nil, NewRuleError(ErrSideChainPowConsensus, "GetCurrentArbiter", err)
 		}
 		if err = CheckSideChainPowConsensus(txn, arbitrtor); err != nil {
-			log.Warn("[CheckSideChainPowConsensus],", err)
-			return ErrSideChainPowConsensus
+			return nil, NewRuleError(ErrSideChainPowConsensus, "CheckSideChainPowConsensus", err)
 		}
 	}
 
+	// check double spent transaction
+	if isDoubleSpend(txn, unconfirmed) {
+		return nil, NewRuleError(ErrDoubleSpend, "CheckTransactionContext",
+			errors.New("IsDoubleSpend check faild"))
+	}
+
+	references, err := getTxReference(txn, unconfirmed)
+	if err != nil {
+		return nil, NewRuleError(ErrUnknownReferedTx, "CheckTransactionContext",
+			fmt.Errorf("get transaction reference failed, %s", err))
+	}
+
 	if txn.IsWithdrawFromSideChainTx() {
-		if err := CheckWithdrawFromSideChainTransaction(txn); err != nil {
-			log.Warn("[CheckWithdrawFromSideChainTransaction],", err)
-			return ErrSidechainTxDuplicate
+		if err := CheckWithdrawFromSideChainTransaction(txn, references); err != nil {
+			return nil, NewRuleError(ErrSidechainTxDuplicate, "CheckWithdrawFromSideChainTransaction", err)
 		}
 	}
 
 	if txn.IsTransferCrossChainAssetTx() {
-		if err := CheckTransferCrossChainAssetTransaction(txn); err != nil {
-			log.Warn("[CheckTransferCrossChainAssetTransaction],", err)
-			return ErrInvalidOutput
+		if err := CheckTransferCrossChainAssetTransaction(txn, references); err != nil {
+			return nil, NewRuleError(ErrInvalidOutput, "CheckTransferCrossChainAssetTransaction", err)
 		}
 	}
 
-	// check double spent transaction
-	if DefaultLedger.IsDoubleSpend(txn) {
-		log.Warn("[CheckTransactionContext] IsDoubleSpend check faild.")
-		return ErrDoubleSpend
+	if err := CheckTransactionUTXOLock(txn, references, blockHeight); err != nil {
+		return nil, NewRuleError(ErrUTXOLocked, "CheckTransactionUTXOLock", err)
 	}
-
-	references, err := DefaultLedger.Store.GetTxReference(txn)
-	if err != nil {
-		log.Warn("[CheckTransactionContext] get transaction reference failed")
-		return ErrUnknownReferedTx
-	}
-
-	if err := CheckTransactionUTXOLock(txn, references); err != nil {
-		log.Warn("[CheckTransactionUTXOLock],", err)
-		return ErrUTXOLocked
+	if err := CheckHTLCLock(txn, references); err != nil {
+		return nil, NewRuleError(ErrUTXOLocked, "CheckHTLCLock", err)
 	}
 
 	if err := CheckTransactionFee(txn, references); err != nil {
-		log.Warn("[CheckTransactionFee],", err)
-		return ErrTransactionBalance
+		return nil, NewRuleError(ErrTransactionBalance, "CheckTransactionFee", err)
 	}
 	if err := CheckDestructionAddress(references); err != nil {
-		log.Warn("[CheckDestructionAddress], ", err)
-		return ErrInvalidInput
+		return nil, NewRuleError(ErrInvalidInput, "CheckDestructionAddress", err)
 	}
-	if err := CheckTransactionSignature(txn, references); err != nil {
-		log.Warn("[CheckTransactionSignature],", err)
-		return ErrTransactionSignature
+	if checkSignature {
+		if err := CheckTransactionSignature(txn, references, blockHeight); err != nil {
+			return nil, NewRuleError(ErrTransactionSignature, "CheckTransactionSignature", err)
+		}
 	}
 
 	if err := CheckTransactionCoinbaseOutputLock(txn); err != nil {
-		log.Warn("[CheckTransactionCoinbaseLock]", err)
-		return ErrIneffectiveCoinbase
+		return nil, NewRuleError(ErrIneffectiveCoinbase, "CheckTransactionCoinbaseLock", err)
 	}
-	return Success
+	return references, nil
 }
 
 func CheckDestructionAddress(references map[*Input]*Output) error {
@@ -150,7 +180,11 @@ func CheckDestructionAddress(references map[*Input]*Output) error {
 func CheckTransactionCoinbaseOutputLock(txn *Transaction) error {
 	for _, input := range txn.Inputs {
 		referHash := input.Previous.TxID
-		referTxn, _, _ := DefaultLedger.Store.GetTransaction(referHash)
+		referTxn, _, err := DefaultLedger.Store.GetTransaction(referHash)
+		if err != nil {
+			// the unconfirmed transactions are never coinbase
+			continue
+		}
 		if referTxn.IsCoinBaseTx() {
 			lockHeight := referTxn.LockTime
 			currentHeight := DefaultLedger.Store.GetHeight()
@@ -196,7 +230,7 @@ func CheckTransactionInput(txn *Transaction) error {
 	return nil
 }
 
-func CheckTransactionOutput(version uint32, txn *Transaction) error {
+func CheckTransactionOutput(version uint32, txn *Transaction, blockHeight uint32) error {
 	if len(txn.Outputs) > math.MaxUint16 {
 		return errors.New("output count should not be greater than 65535(MaxUint16)")
 	}
@@ -238,7 +272,7 @@ func CheckTransactionOutput(version uint32, txn *Transaction) error {
 			return errors.New("Invalide transaction UTXO output.")
 		}
 		if version&CheckTxOut == CheckTxOut {
-			if !CheckOutputProgramHash(output.ProgramHash) {
+			if !CheckOutputProgramHash(output.ProgramHash, blockHeight) {
 				return errors.New("output address is invalid")
 			}
 		}
@@ -248,19 +282,23 @@ func CheckTransactionOutput(version uint32, txn *Transaction) error {
 	return nil
 }
 
-func CheckOutputProgramHash(programHash Uint168) bool {
+// CheckOutputProgramHash returns if the program hash is a valid output
+// address in the block of the height, the HTLC addresses are valid once they
+// are active.
+func CheckOutputProgramHash(programHash Uint168, blockHeight uint32) bool {
 	var empty = Uint168{}
 	prefix := programHash[0]
 	if prefix == PrefixStandard ||
 		prefix == PrefixMultisig ||
 		prefix == PrefixCrossChain ||
+		prefix == PrefixHTLC && isHTLCActive(blockHeight) ||
 		programHash == empty {
 		return true
 	}
 	return false
 }
 
-func CheckTransactionUTXOLock(txn *Transaction, references map[*Input]*Output) error {
+func CheckTransactionUTXOLock(txn *Transaction, references map[*Input]*Output, blockHeight uint32) error {
 	if txn.IsCoinBaseTx() {
 		return nil
 	}
@@ -273,6 +311,12 @@ func CheckTransactionUTXOLock(txn *Transaction, references map[*Input]*Output) e
 		if input.Sequence != math.MaxUint32-1 {
 			return errors.New("Invalid input sequence")
 		}
+		// a height lock can not be passed by a time lock, and vice versa, once
+		// the lock times are timestamps
+		if isTimeLockActive(blockHeight) &&
+			(txn.LockTime >= LockTimeThreshold) != (output.OutputLock >= LockTimeThreshold) {
+			return errors.New("UTXO output lock and transaction lock time are not the same type")
+		}
 		if txn.LockTime < output.OutputLock {
 			return errors.New("UTXO output locked")
 		}
@@ -280,6 +324,45 @@ func CheckTransactionUTXOLock(txn *Transaction, references map[*Input]*Output) e
 	return nil
 }
 
+// CheckHTLCLock checks the HTLC outputs spent by the sender are not spent
+// before the lock height. Like the UTXO lock, the inputs must have the
+// sequence 0xfffffffe and the lock time of the transaction must not be lower
+// than the lock height, so the transaction is not included in a block lower
+// than or at the lock height.
+func CheckHTLCLock(txn *Transaction, references map[*Input]*Output) error {
+	for _, program := range txn.Programs {
+		if !IsHTLCCode(program.Code) {
+			continue
+		}
+		_, preimage, err := ParseHTLCParameter(program.Parameter)
+		if err != nil {
+			return err
+		}
+		// the recipient can spend it any time
+		if preimage != nil {
+			continue
+		}
+
+		script, err := ParseHTLCScript(program.Code)
+		if err != nil {
+			return err
+		}
+		programHash, err := ToHTLCProgramHash(program.Code)
+		if err != nil {
+			return err
+		}
+		for input, output := range references {
+			if output.ProgramHash == *programHash && input.Sequence != math.MaxUint32-1 {
+				return errors.New("Invalid input sequence of HTLC output")
+			}
+		}
+		if txn.LockTime >= LockTimeThreshold || txn.LockTime < script.LockHeight {
+			return errors.New("HTLC output locked")
+		}
+	}
+	return nil
+}
+
 func CheckTransactionSize(txn *Transaction) error {
 	size := txn.GetSize()
 	if size <= 0 || size > config.Parameters.MaxBlockSize {
@@ -352,7 +435,7 @@ func CheckAttributeProgram(tx *Transaction) error {
 		if program.Parameter == nil {
 			return fmt.Errorf("invalid program parameter nil")
 		}
-		_, err := ToProgramHash(program.Code)
+		_, err := toProgramHash(program.Code)
 		if err != nil {
 			return fmt.Errorf("invalid program code %x", program.Code)
 		}
@@ -360,7 +443,7 @@ func CheckAttributeProgram(tx *Transaction) error {
 	return nil
 }
 
-func CheckTransactionSignature(tx *Transaction, references map[*Input]*Output) error {
+func CheckTransactionSignature(tx *Transaction, references map[*Input]*Output, blockHeight uint32) error {
 	hashes, err := GetTxProgramHashes(tx, references)
 	if err != nil {
 		return err
@@ -373,7 +456,7 @@ func CheckTransactionSignature(tx *Transaction, references map[*Input]*Output) e
 	SortProgramHashes(hashes)
 	SortPrograms(tx.Programs)
 
-	return RunPrograms(buf.Bytes(), hashes, tx.Programs)
+	return RunPrograms(buf.Bytes(), hashes, tx.Programs, blockHeight)
 }
 
 func checkAmountPrecise(amount Fixed64, precision byte) bool {
@@ -453,7 +536,7 @@ func CheckSideChainPowConsensus(txn *Transaction, arbitrator []byte) error {
 	return nil
 }
 
-func CheckWithdrawFromSideChainTransaction(txn *Transaction) error {
+func CheckWithdrawFromSideChainTransaction(txn *Transaction, references map[*Input]*Output) error {
 	witPayload, ok := txn.Payload.(*PayloadWithdrawFromSideChain)
 	if !ok {
 		return errors.New("Invalid withdraw from side chain payload type")
@@ -464,11 +547,7 @@ func CheckWithdrawFromSideChainTransaction(txn *Transaction) error {
 		}
 	}
 
-	reference, err := DefaultLedger.Store.GetTxReference(txn)
-	if err != nil {
-		return errors.New("Invalid transaction inputs")
-	}
-	for _, v := range reference {
+	for _, v := range references {
 		if bytes.Compare(v.ProgramHash[0:1], []byte{PrefixCrossChain}) != 0 {
 			return errors.New("Invalid transaction inputs address, without \"X\" at beginning")
 		}
@@ -477,7 +556,7 @@ func CheckWithdrawFromSideChainTransaction(txn *Transaction) error {
 	return nil
 }
 
-func CheckTransferCrossChainAssetTransaction(txn *Transaction) error {
+func CheckTransferCrossChainAssetTransaction(txn *Transaction, references map[*Input]*Output) error {
 	payloadObj, ok := txn.Payload.(*PayloadTransferCrossChainAsset)
 	if !ok {
 		return errors.New("Invalid transfer cross chain asset payload type")
@@ -517,11 +596,7 @@ func CheckTransferCrossChainAssetTransaction(txn *Transaction) error {
 
 	//check transaction fee
 	var totalInput Fixed64
-	reference, err := DefaultLedger.Store.GetTxReference(txn)
-	if err != nil {
-		return errors.New("Invalid transaction inputs")
-	}
-	for _, v := range reference {
+	for _, v := range references {
 		totalInput += v.Value
 	}
 
@@ -71,23 +71,23 @@ func TestCheckOutputProgramHash(t *testing.T) {
 	programHash := common.Uint168{}
 
 	// empty program hash should pass
-	assert.Equal(t, true, CheckOutputProgramHash(programHash))
+	assert.Equal(t, true, CheckOutputProgramHash(programHash, 0))
 
 	// prefix standard program hash should pass
 	programHash[0] = common.PrefixStandard
-	assert.Equal(t, true, CheckOutputProgramHash(programHash))
+	assert.Equal(t, true, CheckOutputProgramHash(programHash, 0))
 
 	// prefix multisig program hash should pass
 	programHash[0] = common.PrefixMultisig
-	assert.Equal(t, true, CheckOutputProgramHash(programHash))
+	assert.Equal(t, true, CheckOutputProgramHash(programHash, 0))
 
 	// prefix crosschain program hash should pass
 	programHash[0] = common.PrefixCrossChain
-	assert.Equal(t, true, CheckOutputProgramHash(programHash))
+	assert.Equal(t, true, CheckOutputProgramHash(programHash, 0))
 
 	// other prefix program hash should not pass
 	programHash[0] = 0x34
-	assert.Equal(t, false, CheckOutputProgramHash(programHash))
+	assert.Equal(t, false, CheckOutputProgramHash(programHash, 0))
 
 	t.Log("[TestCheckOutputProgramHash] PASSED")
 }
@@ -146,14 +146,14 @@ func TestCheckTransactionOutput(t *testing.T) {
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress},
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress},
 	}
-	err := CheckTransactionOutput(core.CheckTxOut, tx)
+	err := CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.NoError(t, err)
 
 	// outputs < 2
 	tx.Outputs = []*core.Output{
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress},
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "coinbase output is not enough, at least 2")
 
 	// invalid asset id
@@ -161,7 +161,7 @@ func TestCheckTransactionOutput(t *testing.T) {
 		{AssetID: common.EmptyHash, ProgramHash: FoundationAddress},
 		{AssetID: common.EmptyHash, ProgramHash: FoundationAddress},
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "asset ID in coinbase is invalid")
 
 	// reward to foundation in coinbase = 30%
@@ -175,7 +175,7 @@ func TestCheckTransactionOutput(t *testing.T) {
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress, Value: foundationReward},
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: common.Uint168{}, Value: minerReward},
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.NoError(t, err)
 
 	// reward to foundation in coinbase < 30%
@@ -187,7 +187,7 @@ func TestCheckTransactionOutput(t *testing.T) {
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress, Value: foundationReward},
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: common.Uint168{}, Value: minerReward},
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "Reward to foundation in coinbase < 30%")
 
 	// normal transaction
@@ -196,12 +196,12 @@ func TestCheckTransactionOutput(t *testing.T) {
 		output.AssetID = DefaultLedger.Blockchain.AssetID
 		output.ProgramHash = common.Uint168{}
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.NoError(t, err)
 
 	// outputs < 1
 	tx.Outputs = nil
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "transaction has no outputs")
 
 	// invalid asset ID
@@ -210,7 +210,7 @@ func TestCheckTransactionOutput(t *testing.T) {
 		output.AssetID = common.EmptyHash
 		output.ProgramHash = common.Uint168{}
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "asset ID in output is invalid")
 
 	// invalid program hash
@@ -221,7 +221,7 @@ func TestCheckTransactionOutput(t *testing.T) {
 		address[0] = 0x23
 		output.ProgramHash = address
 	}
-	err = CheckTransactionOutput(core.CheckTxOut, tx)
+	err = CheckTransactionOutput(core.CheckTxOut, tx, 0)
 	assert.EqualError(t, err, "output address is invalid")
 
 	t.Log("[TestCheckTransactionOutput] PASSED")
@@ -531,3 +531,39 @@ func TestCheckDestructionAddress(t *testing.T) {
 	err := CheckDestructionAddress(reference)
 	assert.EqualError(t, err, fmt.Sprintf("cannot use utxo in the Elastos foundation destruction address"))
 }
+
+func TestCheckTransactionUTXOLock(t *testing.T) {
+	timeLockHeight := config.Parameters.ChainParam.TimeLockHeight
+	defer func() { config.Parameters.ChainParam.TimeLockHeight = timeLockHeight }()
+	config.Parameters.ChainParam.TimeLockHeight = 1000
+
+	tx := newSpendTransaction(core.OutPoint{TxID: common.Uint256{1}})
+	input := tx.Inputs[0]
+	references := map[*core.Input]*core.Output{input: {OutputLock: 100}}
+
+	input.Sequence = math.MaxUint32
+	assert.EqualError(t, CheckTransactionUTXOLock(tx, references, 1000), "Invalid input sequence")
+
+	// a height output lock
+	input.Sequence = math.MaxUint32 - 1
+	tx.LockTime = 99
+	assert.EqualError(t, CheckTransactionUTXOLock(tx, references, 1000), "UTXO output locked")
+	tx.LockTime = 100
+	assert.NoError(t, CheckTransactionUTXOLock(tx, references, 1000))
+	tx.LockTime = LockTimeThreshold
+	assert.EqualError(t, CheckTransactionUTXOLock(tx, references, 1000),
+		"UTXO output lock and transaction lock time are not the same type")
+
+	// the lock times are both heights before the time lock is activated
+	assert.NoError(t, CheckTransactionUTXOLock(tx, references, 999))
+
+	// a time output lock
+	references[input].OutputLock = LockTimeThreshold + 100
+	tx.LockTime = LockTimeThreshold + 99
+	assert.EqualError(t, CheckTransactionUTXOLock(tx, references, 1000), "UTXO output locked")
+	tx.LockTime = LockTimeThreshold + 100
+	assert.NoError(t, CheckTransactionUTXOLock(tx, references, 1000))
+	tx.LockTime = 100
+	assert.Error(t, CheckTransactionUTXOLock(tx, references, 1000))
+	assert.EqualError(t, CheckTransactionUTXOLock(tx, references, 999), "UTXO output locked")
+}
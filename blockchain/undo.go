@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// SpentOutput is an output spent by a block, it is kept in the undo record of
+// the block so the block can be rolled back without looking up the
+// transactions it spent from.
+type SpentOutput struct {
+	Previous OutPoint
+	Output   Output
+	// Height is the height of the block the output was created in.
+	Height uint32
+}
+
+func (s *SpentOutput) Serialize(w io.Writer) error {
+	if err := s.Previous.Serialize(w); err != nil {
+		return err
+	}
+	if err := s.Output.Serialize(w); err != nil {
+		return err
+	}
+	return WriteUint32(w, s.Height)
+}
+
+func (s *SpentOutput) Deserialize(r io.Reader) error {
+	if err := s.Previous.Deserialize(r); err != nil {
+		return err
+	}
+	if err := s.Output.Deserialize(r); err != nil {
+		return err
+	}
+	height, err := ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	s.Height = height
+	return nil
+}
+
+func getBlockUndoKey(hash Uint256) []byte {
+	return append([]byte{byte(DATA_BlockUndo)}, hash.Bytes()...)
+}
+
+// getSpentOutputs returns the outputs spent by the block in input order, the
+// referenced transactions are looked up in the block first because they are
+// not in store yet when the block is being persisted.
+func (c *ChainStore) getSpentOutputs(b *Block) ([]*SpentOutput, error) {
+	blockTxs := make(map[Uint256]*Transaction, len(b.Transactions))
+	for _, txn := range b.Transactions {
+		blockTxs[txn.Hash()] = txn
+	}
+
+	var spentOutputs []*SpentOutput
+	for _, txn := range b.Transactions {
+		if txn.IsCoinBaseTx() {
+			continue
+		}
+		for _, input := range txn.Inputs {
+			height := b.Header.Height
+			referTxn, ok := blockTxs[input.Previous.TxID]
+			if !ok {
+				var err error
+				referTxn, height, err = c.GetTransaction(input.Previous.TxID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			index := input.Previous.Index
+			if int(index) >= len(referTxn.Outputs) {
+				return nil, errors.New("[getSpentOutputs] refer index out of range")
+			}
+			spentOutputs = append(spentOutputs, &SpentOutput{
+				Previous: input.Previous,
+				Output:   *referTxn.Outputs[index],
+				Height:   height,
+			})
+		}
+	}
+	return spentOutputs, nil
+}
+
+// blockSpentOutputs is the outputs spent by a block, in input order and
+// indexed by out point.
+type blockSpentOutputs struct {
+	hash       Uint256
+	outputs    []*SpentOutput
+	byOutPoint map[OutPoint]*SpentOutput
+}
+
+func newBlockSpentOutputs(hash Uint256, spentOutputs []*SpentOutput) *blockSpentOutputs {
+	byOutPoint := make(map[OutPoint]*SpentOutput, len(spentOutputs))
+	for _, spent := range spentOutputs {
+		byOutPoint[spent.Previous] = spent
+	}
+	return &blockSpentOutputs{hash: hash, outputs: spentOutputs, byOutPoint: byOutPoint}
+}
+
+// cachedSpentOutputs returns the outputs spent by the block being committed,
+// or nil if it is not the block.
+func (c *ChainStore) cachedSpentOutputs(b *Block) *blockSpentOutputs {
+	if c.blockSpent == nil || !c.blockSpent.hash.IsEqual(b.Hash()) {
+		return nil
+	}
+	return c.blockSpent
+}
+
+// key: DATA_BlockUndo || block hash
+// value: count || spent outputs
+func (c *ChainStore) PersistBlockUndo(b *Block) error {
+	var spentOutputs []*SpentOutput
+	if cached := c.cachedSpentOutputs(b); cached != nil {
+		spentOutputs = cached.outputs
+	} else {
+		var err error
+		if spentOutputs, err = c.getSpentOutputs(b); err != nil {
+			return err
+		}
+	}
+
+	value := new(bytes.Buffer)
+	if err := WriteVarUint(value, uint64(len(spentOutputs))); err != nil {
+		return err
+	}
+	for _, spent := range spentOutputs {
+		if err := spent.Serialize(value); err != nil {
+			return err
+		}
+	}
+
+	c.BatchPut(getBlockUndoKey(b.Hash()), value.Bytes())
+	return nil
+}
+
+func (c *ChainStore) RollbackBlockUndo(b *Block) error {
+	c.BatchDelete(getBlockUndoKey(b.Hash()))
+	return nil
+}
+
+// GetBlockUndo returns the outputs spent by the block with the given hash.
+func (c *ChainStore) GetBlockUndo(hash Uint256) ([]*SpentOutput, error) {
+	data, err := c.Get(getBlockUndoKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(data)
+	count, err := ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	// every spent output takes more than one byte
+	if count > uint64(len(data)) {
+		return nil, errors.New("[GetBlockUndo] invalid spent output count")
+	}
+	spentOutputs := make([]*SpentOutput, 0, count)
+	for i := uint64(0); i < count; i++ {
+		spent := new(SpentOutput)
+		if err := spent.Deserialize(r); err != nil {
+			return nil, err
+		}
+		spentOutputs = append(spentOutputs, spent)
+	}
+	return spentOutputs, nil
+}
+
+// loadSpentOutputs returns the outputs spent by the block in input order.
+// The undo record is used if there is one, the blocks being persisted and the
+// blocks persisted by older versions have no undo record and the referenced
+// transactions are looked up instead.
+func (c *ChainStore) loadSpentOutputs(b *Block) ([]*SpentOutput, error) {
+	spentOutputs, err := c.GetBlockUndo(b.Hash())
+	if err != nil {
+		return c.getSpentOutputs(b)
+	}
+	return spentOutputs, nil
+}
+
+// getBlockSpentOutputs returns the outputs spent by the block indexed by
+// out point, they are loaded only once for the block being committed.
+func (c *ChainStore) getBlockSpentOutputs(b *Block) (map[OutPoint]*SpentOutput, error) {
+	if cached := c.cachedSpentOutputs(b); cached != nil {
+		return cached.byOutPoint, nil
+	}
+	spentOutputs, err := c.loadSpentOutputs(b)
+	if err != nil {
+		return nil, err
+	}
+	return newBlockSpentOutputs(b.Hash(), spentOutputs).byOutPoint, nil
+}
@@ -0,0 +1,257 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSpendTransaction(outPoints ...core.OutPoint) *core.Transaction {
+	txn := &core.Transaction{
+		TxType:  core.TransferAsset,
+		Payload: &core.PayloadTransferAsset{},
+	}
+	for _, outPoint := range outPoints {
+		txn.Inputs = append(txn.Inputs, &core.Input{Previous: outPoint})
+	}
+	return txn
+}
+
+func TestChainStore_BlockUndo(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	coinbase := genesis.Transactions[0]
+	spender := newSpendTransaction(core.OutPoint{TxID: coinbase.Hash(), Index: 0})
+	spender.Outputs = []*core.Output{{
+		AssetID:     coinbase.Outputs[0].AssetID,
+		Value:       coinbase.Outputs[0].Value,
+		ProgramHash: common.Uint168{0x56, 0x78},
+	}}
+	block.Transactions = append(block.Transactions, spender)
+
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+	entries := dumpStore(store.IStore)
+
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	spentOutputs, err := store.GetBlockUndo(block.Hash())
+	if assert.NoError(t, err) && assert.Len(t, spentOutputs, 1) {
+		assert.Equal(t, spender.Inputs[0].Previous, spentOutputs[0].Previous)
+		assert.Equal(t, *coinbase.Outputs[0], spentOutputs[0].Output)
+		assert.Equal(t, uint32(0), spentOutputs[0].Height)
+	}
+
+	// rollback with the undo record
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	assert.Equal(t, entries, dumpStore(store.IStore))
+
+	// a block persisted by older versions has no undo record
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	assert.NoError(t, store.Delete(getBlockUndoKey(block.Hash())))
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	assert.Equal(t, entries, dumpStore(store.IStore))
+}
+
+func TestChainStore_ChainedTransactions(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	// spender2 spends the output of spender1 in the same block
+	coinbase := genesis.Transactions[0]
+	spender1 := newSpendTransaction(core.OutPoint{TxID: coinbase.Hash(), Index: 0})
+	spender1.Outputs = []*core.Output{{
+		AssetID:     coinbase.Outputs[0].AssetID,
+		Value:       coinbase.Outputs[0].Value,
+		ProgramHash: common.Uint168{0x56, 0x78},
+	}}
+	spender2 := newSpendTransaction(core.OutPoint{TxID: spender1.Hash(), Index: 0})
+	spender2.Outputs = []*core.Output{{
+		AssetID:     coinbase.Outputs[0].AssetID,
+		Value:       coinbase.Outputs[0].Value,
+		ProgramHash: common.Uint168{0x9a, 0xbc},
+	}}
+	block.Transactions = append(block.Transactions, spender1, spender2)
+
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+	entries := dumpStore(store.IStore)
+
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	unspent, _ := store.ContainsUnspent(spender1.Hash(), 0)
+	assert.False(t, unspent)
+	unspent, _ = store.ContainsUnspent(spender2.Hash(), 0)
+	assert.True(t, unspent)
+	utxos, err := store.GetUnspentFromProgramHash(common.Uint168{0x56, 0x78}, coinbase.Outputs[0].AssetID)
+	if assert.NoError(t, err) {
+		for _, utxo := range utxos {
+			assert.NotEqual(t, spender1.Hash(), utxo.TxId)
+		}
+	}
+	utxos, err = store.GetUnspentFromProgramHash(common.Uint168{0x9a, 0xbc}, coinbase.Outputs[0].AssetID)
+	if assert.NoError(t, err) && assert.Len(t, utxos, 1) {
+		assert.Equal(t, spender2.Hash(), utxos[0].TxId)
+	}
+
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	assert.Equal(t, entries, dumpStore(store.IStore))
+
+	// rollback without the undo record
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	assert.NoError(t, store.Delete(getBlockUndoKey(block.Hash())))
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	assert.Equal(t, entries, dumpStore(store.IStore))
+}
+
+// lookupStore counts the entries of the prefix looked up from the store.
+type lookupStore struct {
+	IStore
+	prefix  DataEntryPrefix
+	lookups int
+}
+
+func (s *lookupStore) Get(key []byte) ([]byte, error) {
+	if len(key) > 0 && key[0] == byte(s.prefix) {
+		s.lookups++
+	}
+	return s.IStore.Get(key)
+}
+
+func TestChainStore_SpentOutputsLookup(t *testing.T) {
+	store, genesis, block := newRecoveryTestStore(t)
+	defer store.Close()
+
+	coinbase := genesis.Transactions[0]
+	spender := newSpendTransaction(core.OutPoint{TxID: coinbase.Hash(), Index: 0})
+	spender.Outputs = []*core.Output{{
+		AssetID:     coinbase.Outputs[0].AssetID,
+		Value:       coinbase.Outputs[0].Value,
+		ProgramHash: common.Uint168{0x56, 0x78},
+	}}
+	block.Transactions = append(block.Transactions, spender)
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	// the referenced transaction is looked up once for all persist steps
+	txLookups := &lookupStore{IStore: store.IStore, prefix: DATA_Transaction}
+	store.IStore = txLookups
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	assert.Equal(t, 1, txLookups.lookups)
+
+	// and not at all with the undo record
+	txLookups.lookups = 0
+	if !assert.NoError(t, store.commitBlockSteps(block, blockRollbackSteps)) {
+		return
+	}
+	assert.Equal(t, 0, txLookups.lookups)
+}
+
+// BenchmarkChainStore_CommitBlock measures the persist of a block spending
+// from many transactions, and compares the rollback of it with the undo
+// record, without the undo record as a block stored by an older version, and
+// the rollback before the undo records, which looked up the spent
+// transactions again in every step.
+func BenchmarkChainStore_CommitBlock(b *testing.B) {
+	const fundingTxs, outputsPerTx = 200, 20
+
+	store, genesis, _ := newRecoveryTestStore(b)
+	defer store.Close()
+	if err := store.persist(genesis); err != nil {
+		b.Fatal(err)
+	}
+
+	assetID := genesis.Transactions[1].Hash()
+	funding := &core.Block{Header: core.Header{Height: 1, Previous: genesis.Hash()}}
+	var outPoints []core.OutPoint
+	for i := 0; i < fundingTxs; i++ {
+		txn := NewCoinBaseTransaction(&core.PayloadCoinBase{}, uint32(i))
+		for j := 0; j < outputsPerTx; j++ {
+			txn.Outputs = append(txn.Outputs, &core.Output{
+				AssetID:     assetID,
+				Value:       common.Fixed64(j + 1),
+				ProgramHash: common.Uint168{byte(j)},
+			})
+		}
+		funding.Transactions = append(funding.Transactions, txn)
+		outPoints = append(outPoints, core.OutPoint{TxID: txn.Hash(), Index: 0})
+	}
+	if err := store.persist(funding); err != nil {
+		b.Fatal(err)
+	}
+
+	spending := &core.Block{
+		Header:       core.Header{Height: 2, Previous: funding.Hash()},
+		Transactions: []*core.Transaction{newSpendTransaction(outPoints...)},
+	}
+
+	// the steps run without the spent outputs loaded for the block, so
+	// each step looks up the spent transactions by itself
+	rollbackPerStep := func() error {
+		store.NewBatch()
+		for _, step := range blockRollbackSteps {
+			if err := step(store, spending); err != nil {
+				return err
+			}
+		}
+		return store.BatchCommit()
+	}
+	rollback := func(b *testing.B, keepUndo bool, commit func() error) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			if err := store.persist(spending); err != nil {
+				b.Fatal(err)
+			}
+			if !keepUndo {
+				if err := store.Delete(getBlockUndoKey(spending.Hash())); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StartTimer()
+			if err := commit(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	rollbackOnce := func() error {
+		return store.commitBlockSteps(spending, blockRollbackSteps)
+	}
+	b.Run("persist", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := store.persist(spending); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			if err := rollbackOnce(); err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+		}
+	})
+	b.Run("rollback/undo", func(b *testing.B) { rollback(b, true, rollbackOnce) })
+	b.Run("rollback/lookup", func(b *testing.B) { rollback(b, false, rollbackOnce) })
+	b.Run("rollback/lookup-per-step", func(b *testing.B) { rollback(b, false, rollbackPerStep) })
+}
@@ -0,0 +1,477 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	. "github.com/elastos/Elastos.ELA/core"
+	"github.com/elastos/Elastos.ELA/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// UTXO snapshot is the UTXO set of the chain at a height, along with the
+// data needed by a node to continue the chain from that height. It is
+// magic(uint32) || height(uint32) || block hash || records || 0x00 || UTXO set hash
+// and each record is 0x01 || key(var bytes) || value(var bytes).
+//
+// The records are the hashes and headers of the genesis block and the recent
+// blocks loaded at start up, the transactions and undo records of the genesis
+// block and the recent blocks, the transactions having unspent outputs, the
+// sidechain transaction index, the asset info, the current block, the pruned
+// height and the UTXO set. A node restored from a snapshot is pruned below
+// the recent blocks, it has no transactions which were spent before them, so
+// their history can not be queried, and the chain can not be reorganized
+// below them.
+
+const (
+	snapshotRecordFlag = 0x01
+	snapshotEndFlag    = 0x00
+
+	// snapshotBatchRecords is the number of records committed in one batch
+	// when restoring a snapshot.
+	snapshotBatchRecords = 10000
+)
+
+// canonicalUnspent returns the value of an IX_Unspent entry with the output
+// indexes sorted, the order in store depends on how the outputs were spent.
+func canonicalUnspent(value []byte) ([]byte, error) {
+	indexes, err := GetUint16Array(value)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return ToByteArray(indexes), nil
+}
+
+// canonicalUnspentUTXO returns the value of an IX_Unspent_UTXO entry with the
+// UTXOs sorted by transaction hash and output index.
+func canonicalUnspentUTXO(value []byte) ([]byte, error) {
+	r := bytes.NewReader(value)
+	listNum, err := ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	unspents := make([]*UTXO, listNum)
+	for i := range unspents {
+		unspents[i] = new(UTXO)
+		if err := unspents[i].Deserialize(r); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(unspents, func(i, j int) bool {
+		if c := unspents[i].TxId.Compare(unspents[j].TxId); c != 0 {
+			return c < 0
+		}
+		return unspents[i].Index < unspents[j].Index
+	})
+
+	w := new(bytes.Buffer)
+	WriteVarUint(w, uint64(listNum))
+	for _, unspent := range unspents {
+		unspent.Serialize(w)
+	}
+	return w.Bytes(), nil
+}
+
+// iterateUTXOSet calls handler with the entries of IX_Unspent and
+// IX_Unspent_UTXO of the store snapshot in key order, the values are in
+// canonical form.
+func iterateUTXOSet(snapshot IStoreSnapshot, handler func(key, value []byte) error) error {
+	prefixes := []struct {
+		prefix    DataEntryPrefix
+		canonical func([]byte) ([]byte, error)
+	}{
+		{IX_Unspent, canonicalUnspent},
+		{IX_Unspent_UTXO, canonicalUnspentUTXO},
+	}
+
+	for _, p := range prefixes {
+		iter := snapshot.NewIterator([]byte{byte(p.prefix)})
+		for iter.Next() {
+			value, err := p.canonical(iter.Value())
+			if err != nil {
+				iter.Release()
+				return err
+			}
+			if err := handler(iter.Key(), value); err != nil {
+				iter.Release()
+				return err
+			}
+		}
+		iter.Release()
+	}
+	return nil
+}
+
+// fullReader fills the whole buffer on each Read, the deserialize functions
+// expect it while a buffered file reader may return less bytes.
+type fullReader struct {
+	r io.Reader
+}
+
+func (f *fullReader) Read(p []byte) (int, error) {
+	return io.ReadFull(f.r, p)
+}
+
+func writeSnapshotRecord(w io.Writer, key, value []byte) error {
+	if _, err := w.Write([]byte{snapshotRecordFlag}); err != nil {
+		return err
+	}
+	if err := WriteVarBytes(w, key); err != nil {
+		return err
+	}
+	return WriteVarBytes(w, value)
+}
+
+func hashSnapshotRecord(h hash.Hash, key, value []byte) {
+	WriteVarBytes(h, key)
+	WriteVarBytes(h, value)
+}
+
+// GetUTXOSetHash returns the deterministic hash of the UTXO set, nodes on
+// the same chain at the same height have the same UTXO set hash.
+func (c *ChainStore) GetUTXOSetHash() (Uint256, error) {
+	snapshot, err := c.NewSnapshot()
+	if err != nil {
+		return Uint256{}, err
+	}
+	defer snapshot.Release()
+
+	h := sha256.New()
+	err = iterateUTXOSet(snapshot, func(key, value []byte) error {
+		hashSnapshotRecord(h, key, value)
+		return nil
+	})
+	if err != nil {
+		return Uint256{}, err
+	}
+
+	var setHash Uint256
+	copy(setHash[:], h.Sum(nil))
+	return setHash, nil
+}
+
+// getSnapshotBlockHash returns the hash of the block at the height in the
+// store snapshot.
+func getSnapshotBlockHash(snapshot IStoreSnapshot, height uint32) (Uint256, error) {
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(DATA_BlockHash))
+	if err := WriteUint32(key, height); err != nil {
+		return Uint256{}, err
+	}
+	data, err := snapshot.Get(key.Bytes())
+	if err != nil {
+		return Uint256{}, err
+	}
+	blockHash, err := Uint256FromBytes(data)
+	if err != nil {
+		return Uint256{}, err
+	}
+	return *blockHash, nil
+}
+
+// CreateTipUTXOSnapshot writes the snapshot of the UTXO set at the best
+// height of the store to w, it returns the height and the UTXO set hash.
+// Older heights are not supported, the store keeps the indexes of the best
+// height only, the UTXO set, asset info and sidechain transaction index of an
+// older height would be rebuilt by rolling back every block above it, which
+// needs the blocks a pruned node no longer has. The snapshot is read from a
+// store snapshot, and each block is committed in one batch, so the snapshot
+// is of one height while blocks are being persisted.
+func (c *ChainStore) CreateTipUTXOSnapshot(w io.Writer, magic uint32) (uint32, Uint256, error) {
+	snapshot, err := c.NewSnapshot()
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+	defer snapshot.Release()
+
+	currentBlock, err := snapshot.Get([]byte{byte(SYS_CurrentBlock)})
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+	r := bytes.NewReader(currentBlock)
+	var blockHash Uint256
+	if err := blockHash.Deserialize(r); err != nil {
+		return 0, Uint256{}, err
+	}
+	height, err := ReadUint32(r)
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+
+	if err := WriteUint32(w, magic); err != nil {
+		return 0, Uint256{}, err
+	}
+	if err := WriteUint32(w, height); err != nil {
+		return 0, Uint256{}, err
+	}
+	if err := blockHash.Serialize(w); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	writeEntry := func(key []byte) error {
+		value, err := snapshot.Get(key)
+		if err != nil {
+			return err
+		}
+		return writeSnapshotRecord(w, key, value)
+	}
+	writePrefix := func(prefix DataEntryPrefix) error {
+		iter := snapshot.NewIterator([]byte{byte(prefix)})
+		defer iter.Release()
+		for iter.Next() {
+			if err := writeSnapshotRecord(w, iter.Key(), iter.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// the block hashes and headers needed to load the recent block nodes,
+	// and the genesis block which is checked at start up
+	startHeight := uint32(0)
+	if height > MinMemoryNodes {
+		startHeight = height - MinMemoryNodes
+	}
+	heights := []uint32{0}
+	for h := startHeight; h <= height; h++ {
+		if h != 0 {
+			heights = append(heights, h)
+		}
+	}
+
+	// the restored store is pruned below the recent blocks, or below the
+	// pruned height of this store if it is higher
+	prunedHeight := startHeight + 1
+	if data, err := snapshot.Get([]byte{byte(SYS_PrunedHeight)}); err == nil {
+		stored, err := ReadUint32(bytes.NewReader(data))
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		if stored > prunedHeight {
+			prunedHeight = stored
+		}
+	}
+
+	written := make(map[Uint256]struct{})
+	for _, h := range heights {
+		headerHash, err := getSnapshotBlockHash(snapshot, h)
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		key := new(bytes.Buffer)
+		key.WriteByte(byte(DATA_BlockHash))
+		if err := WriteUint32(key, h); err != nil {
+			return 0, Uint256{}, err
+		}
+		if err := writeEntry(key.Bytes()); err != nil {
+			return 0, Uint256{}, err
+		}
+		headerKey := append([]byte{byte(DATA_Header)}, headerHash.Bytes()...)
+		headerData, err := snapshot.Get(headerKey)
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		if err := writeSnapshotRecord(w, headerKey, headerData); err != nil {
+			return 0, Uint256{}, err
+		}
+		if h != 0 && h < prunedHeight {
+			continue
+		}
+
+		// the transactions and the undo record of the block which is not
+		// pruned, the blocks stored by older versions have no undo record
+		r := bytes.NewReader(headerData)
+		// first 8 bytes is sys_fee
+		if _, err := ReadUint64(r); err != nil {
+			return 0, Uint256{}, err
+		}
+		trimmed := new(Block)
+		if err := trimmed.FromTrimmedData(r); err != nil {
+			return 0, Uint256{}, err
+		}
+		for _, txn := range trimmed.Transactions {
+			txHash := txn.Hash()
+			if _, ok := written[txHash]; ok {
+				continue
+			}
+			written[txHash] = struct{}{}
+			if err := writeEntry(append([]byte{byte(DATA_Transaction)}, txHash.Bytes()...)); err != nil {
+				return 0, Uint256{}, err
+			}
+		}
+		if undo, err := snapshot.Get(getBlockUndoKey(headerHash)); err == nil {
+			if err := writeSnapshotRecord(w, getBlockUndoKey(headerHash), undo); err != nil {
+				return 0, Uint256{}, err
+			}
+		}
+	}
+
+	// the transactions which have unspent outputs
+	iter := snapshot.NewIterator([]byte{byte(IX_Unspent)})
+	for iter.Next() {
+		txHash, err := Uint256FromBytes(iter.Key()[1:])
+		if err != nil {
+			iter.Release()
+			return 0, Uint256{}, err
+		}
+		if _, ok := written[*txHash]; ok {
+			continue
+		}
+		if err := writeEntry(append([]byte{byte(DATA_Transaction)}, txHash.Bytes()...)); err != nil {
+			iter.Release()
+			return 0, Uint256{}, err
+		}
+	}
+	iter.Release()
+
+	for _, prefix := range []DataEntryPrefix{IX_SideChain_Tx, ST_Info} {
+		if err := writePrefix(prefix); err != nil {
+			return 0, Uint256{}, err
+		}
+	}
+	if err := writeSnapshotRecord(w, []byte{byte(SYS_CurrentBlock)}, currentBlock); err != nil {
+		return 0, Uint256{}, err
+	}
+	value := new(bytes.Buffer)
+	if err := WriteUint32(value, prunedHeight); err != nil {
+		return 0, Uint256{}, err
+	}
+	if err := writeSnapshotRecord(w, []byte{byte(SYS_PrunedHeight)}, value.Bytes()); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	// the UTXO set
+	h := sha256.New()
+	err = iterateUTXOSet(snapshot, func(key, value []byte) error {
+		hashSnapshotRecord(h, key, value)
+		return writeSnapshotRecord(w, key, value)
+	})
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+
+	var setHash Uint256
+	copy(setHash[:], h.Sum(nil))
+	if _, err := w.Write([]byte{snapshotEndFlag}); err != nil {
+		return 0, Uint256{}, err
+	}
+	if err := setHash.Serialize(w); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	return height, setHash, nil
+}
+
+// RestoreUTXOSnapshot loads a snapshot created by CreateUTXOSnapshot into
+// the store, the store must be empty. The store version is written only
+// after the UTXO set hash is verified, so a store with a broken restore is
+// wiped at the next start up. It returns the height and the UTXO set hash.
+func (c *ChainStore) RestoreUTXOSnapshot(r io.Reader, magic uint32) (uint32, Uint256, error) {
+	r = &fullReader{r: r}
+
+	iter := c.NewIterator(nil)
+	empty := !iter.Next()
+	iter.Release()
+	if !empty {
+		return 0, Uint256{}, errors.New("store is not empty")
+	}
+
+	fileMagic, err := ReadUint32(r)
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+	if fileMagic != magic {
+		return 0, Uint256{}, fmt.Errorf("unmatched magic %d, expect %d", fileMagic, magic)
+	}
+	height, err := ReadUint32(r)
+	if err != nil {
+		return 0, Uint256{}, err
+	}
+	var blockHash Uint256
+	if err := blockHash.Deserialize(r); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	h := sha256.New()
+	count := 0
+	c.NewBatch()
+	for {
+		flag, err := ReadBytes(r, 1)
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		if flag[0] == snapshotEndFlag {
+			break
+		}
+		if flag[0] != snapshotRecordFlag {
+			return 0, Uint256{}, fmt.Errorf("invalid record flag %d", flag[0])
+		}
+
+		key, err := ReadVarBytes(r)
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		value, err := ReadVarBytes(r)
+		if err != nil {
+			return 0, Uint256{}, err
+		}
+		if len(key) == 0 {
+			return 0, Uint256{}, errors.New("empty record key")
+		}
+		switch DataEntryPrefix(key[0]) {
+		case IX_Unspent, IX_Unspent_UTXO:
+			hashSnapshotRecord(h, key, value)
+		case CFG_Version, CFG_Migration, CFG_NetworkMagic:
+			return 0, Uint256{}, errors.New("unexpected config record")
+		}
+
+		c.BatchPut(key, value)
+		count++
+		if count%snapshotBatchRecords == 0 {
+			if err := c.BatchCommit(); err != nil {
+				return 0, Uint256{}, err
+			}
+			log.Infof("[RestoreUTXOSnapshot] restored %d records", count)
+			c.NewBatch()
+		}
+	}
+
+	var fileHash Uint256
+	if err := fileHash.Deserialize(r); err != nil {
+		return 0, Uint256{}, err
+	}
+	var setHash Uint256
+	copy(setHash[:], h.Sum(nil))
+	if !setHash.IsEqual(fileHash) {
+		return 0, Uint256{}, errors.New("UTXO set hash does not match")
+	}
+
+	if err := c.BatchCommit(); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	// the block of the snapshot height must be restored, and the store must
+	// be marked pruned below the blocks restored with their transactions
+	restoredHash, err := c.GetBlockHash(height)
+	if err != nil || !restoredHash.IsEqual(blockHash) {
+		return 0, Uint256{}, errors.New("block of the snapshot height is missing")
+	}
+	if c.GetPrunedHeight() == 0 {
+		return 0, Uint256{}, errors.New("pruned height of the snapshot is missing")
+	}
+	if err := c.putNetworkMagic(magic); err != nil {
+		return 0, Uint256{}, err
+	}
+	if err := c.Put([]byte{byte(CFG_Version)}, []byte{CurrentStoreVersion}); err != nil {
+		return 0, Uint256{}, err
+	}
+
+	return height, setHash, nil
+}
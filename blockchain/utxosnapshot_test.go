@@ -0,0 +1,222 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOSnapshot(t *testing.T) {
+	store, genesis := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, store.persist(genesis)) {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	snapshotHeight, setHash, err := store.CreateTipUTXOSnapshot(buf, 1234)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint32(0), snapshotHeight)
+	storeHash, err := store.GetUTXOSetHash()
+	assert.NoError(t, err)
+	assert.Equal(t, storeHash, setHash)
+	data := buf.Bytes()
+
+	// a store which is not empty can not be restored
+	_, _, err = store.RestoreUTXOSnapshot(bytes.NewReader(data), 1234)
+	assert.Error(t, err)
+
+	newStore := func() *ChainStore {
+		st, err := NewMemLevelDB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newChainStore(st)
+	}
+
+	// restore into an empty store
+	restored := newStore()
+	defer restored.Close()
+	height, restoredHash, err := restored.RestoreUTXOSnapshot(bytes.NewReader(data), 1234)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint32(0), height)
+	assert.Equal(t, setHash, restoredHash)
+	storeHash, err = restored.GetUTXOSetHash()
+	assert.NoError(t, err)
+	assert.Equal(t, setHash, storeHash)
+	assert.Equal(t, byte(CurrentStoreVersion), restored.getStoreVersion())
+	assert.NoError(t, restored.checkNetworkMagic(1234))
+	assert.Error(t, restored.checkNetworkMagic(4321))
+	assert.True(t, restored.IsBlockInStore(genesis.Hash()))
+	coinbase := genesis.Transactions[0]
+	unspent, err := restored.GetUnspent(coinbase.Hash(), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, coinbase.Outputs[0].Value, unspent.Value)
+	}
+
+	// snapshot of another network
+	another := newStore()
+	defer another.Close()
+	_, _, err = another.RestoreUTXOSnapshot(bytes.NewReader(data), 4321)
+	assert.Error(t, err)
+
+	// the UTXO set hash is the last 32 bytes
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	broken := newStore()
+	defer broken.Close()
+	_, _, err = broken.RestoreUTXOSnapshot(bytes.NewReader(corrupted), 1234)
+	assert.Error(t, err)
+	assert.Equal(t, byte(0x00), broken.getStoreVersion())
+
+	// the snapshot follows the best height
+	block := &core.Block{
+		Header:       core.Header{Height: 1, Previous: genesis.Hash()},
+		Transactions: []*core.Transaction{NewCoinBaseTransaction(&core.PayloadCoinBase{}, 1)},
+	}
+	block.Transactions[0].Outputs = []*core.Output{{AssetID: genesis.Transactions[1].Hash(), Value: 1}}
+	if !assert.NoError(t, store.persist(block)) {
+		return
+	}
+	snapshotHeight, tipHash, err := store.CreateTipUTXOSnapshot(new(bytes.Buffer), 1234)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), snapshotHeight)
+	assert.NotEqual(t, setHash, tipHash)
+}
+
+func TestUTXOSnapshotRecentBlocks(t *testing.T) {
+	l, restore := newPoolTestLedger(t, 1)
+	defer restore()
+	minMemoryNodes := MinMemoryNodes
+	MinMemoryNodes = 2
+	defer func() { MinMemoryNodes = minMemoryNodes }()
+
+	// each of the blocks 2 to 4 spends the output of the previous one, the
+	// blocks 2 to 4 are loaded at start up and the blocks 3 and 4 are kept
+	// with their transactions
+	outPoint := l.depositOutPoint(0)
+	previous, err := l.store.GetBlockHash(1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var blocks []*core.Block
+	var txs []*core.Transaction
+	for height := uint32(2); height <= 4; height++ {
+		txn := l.spend(t, 1000, outPoint)
+		block := &core.Block{
+			Header: core.Header{Height: height, Previous: previous},
+			Transactions: []*core.Transaction{
+				NewCoinBaseTransaction(&core.PayloadCoinBase{}, height), txn,
+			},
+		}
+		if !assert.NoError(t, l.store.persist(block)) {
+			return
+		}
+		blocks = append(blocks, block)
+		txs = append(txs, txn)
+		outPoint = core.OutPoint{TxID: txn.Hash()}
+		previous = block.Hash()
+	}
+
+	buf := new(bytes.Buffer)
+	height, setHash, err := l.store.CreateTipUTXOSnapshot(buf, config.Parameters.Magic)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint32(4), height)
+
+	st, err := NewMemLevelDB()
+	if !assert.NoError(t, err) {
+		return
+	}
+	restored := newChainStore(st)
+	defer restored.Close()
+	_, _, err = restored.RestoreUTXOSnapshot(bytes.NewReader(buf.Bytes()), config.Parameters.Magic)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the store is pruned below the blocks kept with their transactions
+	assert.Equal(t, uint32(3), restored.GetPrunedHeight())
+	assert.False(t, restored.IsBlockPruned(DefaultLedger.Blockchain.GenesisHash))
+	assert.True(t, restored.IsBlockPruned(blocks[0].Hash()))
+	assert.False(t, restored.IsBlockPruned(blocks[1].Hash()))
+	assert.False(t, restored.IsBlockPruned(blocks[2].Hash()))
+	_, _, err = restored.GetTransaction(txs[0].Hash())
+	assert.Error(t, err)
+	_, _, err = restored.GetTransaction(txs[1].Hash())
+	assert.NoError(t, err)
+	undo, err := l.store.GetBlockUndo(blocks[2].Hash())
+	assert.NoError(t, err)
+	restoredUndo, err := restored.GetBlockUndo(blocks[2].Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, undo, restoredUndo)
+
+	// the restored store starts up at the snapshot height
+	genesis, err := l.store.GetBlock(DefaultLedger.Blockchain.GenesisHash)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assetID := DefaultLedger.Blockchain.AssetID
+	DefaultLedger = &Ledger{Blockchain: NewBlockchain(0), Store: restored}
+	DefaultLedger.Blockchain.AssetID = assetID
+	height, err = restored.InitWithGenesisBlock(genesis)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint32(4), height)
+	storeHash, err := restored.GetUTXOSetHash()
+	assert.NoError(t, err)
+	assert.Equal(t, setHash, storeHash)
+
+	// the recent block is rolled back as it is in the original store
+	for _, store := range []*ChainStore{l.store, restored} {
+		block, err := store.GetBlock(blocks[2].Hash())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, store.rollback(block))
+	}
+	setHash, err = l.store.GetUTXOSetHash()
+	assert.NoError(t, err)
+	storeHash, err = restored.GetUTXOSetHash()
+	assert.NoError(t, err)
+	assert.Equal(t, setHash, storeHash)
+	unspent, err := restored.GetUnspent(txs[1].Hash(), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, txs[1].Outputs[0].Value, unspent.Value)
+	}
+}
+
+func TestCanonicalUnspentUTXO(t *testing.T) {
+	utxos := []*UTXO{
+		{TxId: common.Uint256{2}, Index: 0, Value: 1},
+		{TxId: common.Uint256{1}, Index: 1, Value: 2},
+		{TxId: common.Uint256{1}, Index: 0, Value: 3},
+	}
+	serialize := func(list []*UTXO) []byte {
+		w := new(bytes.Buffer)
+		common.WriteVarUint(w, uint64(len(list)))
+		for _, u := range list {
+			u.Serialize(w)
+		}
+		return w.Bytes()
+	}
+
+	value, err := canonicalUnspentUTXO(serialize(utxos))
+	assert.NoError(t, err)
+	assert.Equal(t, serialize([]*UTXO{utxos[2], utxos[1], utxos[0]}), value)
+
+	indexes, err := canonicalUnspent(ToByteArray([]uint16{3, 1, 2}))
+	assert.NoError(t, err)
+	assert.Equal(t, ToByteArray([]uint16{1, 2, 3}), indexes)
+}
@@ -14,13 +14,16 @@ import (
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
 )
 
-func RunPrograms(data []byte, hashes []common.Uint168, programs []*Program) error {
+// RunPrograms checks the programs of the data hashes, the HTLC programs are
+// checked only once they are active at the block height.
+func RunPrograms(data []byte, hashes []common.Uint168, programs []*Program, blockHeight uint32) error {
 	if len(hashes) != len(programs) {
 		return errors.New("The number of data hashes is different with number of programs.")
 	}
 
+	dataHash := common.Uint256(sha256.Sum256(data))
 	for i, program := range programs {
-		programHash, err := crypto.ToProgramHash(program.Code)
+		programHash, err := toProgramHash(program.Code)
 		if err != nil {
 			return err
 		}
@@ -33,6 +36,15 @@ func RunPrograms(data []byte, hashes []common.Uint168, programs []*Program) erro
 		if !hashes[i].IsEqual(*programHash) && signType != common.CROSSCHAIN {
 			return errors.New("The data hashes is different with corresponding program code.")
 		}
+		if signType == HTLC && !isHTLCActive(blockHeight) {
+			return errors.New("HTLC program is not active")
+		}
+
+		// skip the signatures verified before
+		key := sigCacheKey(dataHash, program)
+		if DefaultSigCache.Exists(key) {
+			continue
+		}
 
 		if signType == common.STANDARD {
 			if err := checkStandardSignature(*program, data); err != nil {
@@ -49,14 +61,29 @@ func RunPrograms(data []byte, hashes []common.Uint168, programs []*Program) erro
 				return err
 			}
 
+		} else if signType == HTLC {
+			if err = checkHTLCSignature(*program, data); err != nil {
+				return err
+			}
+
 		} else {
 			return errors.New("unknown signature type")
 		}
+		DefaultSigCache.Add(key)
 	}
 
 	return nil
 }
 
+// toProgramHash returns the program hash of the program code, including the
+// HTLC program codes.
+func toProgramHash(code []byte) (*common.Uint168, error) {
+	if IsHTLCCode(code) {
+		return ToHTLCProgramHash(code)
+	}
+	return crypto.ToProgramHash(code)
+}
+
 func GetTxProgramHashes(tx *Transaction, references map[*Input]*Output) ([]common.Uint168, error) {
 	if tx == nil {
 		return nil, errors.New("[Transaction],GetProgramHashes transaction is nil.")
@@ -102,6 +129,29 @@ func checkStandardSignature(program Program, data []byte) error {
 	return crypto.Verify(*publicKey, data, program.Parameter[1:])
 }
 
+// checkHTLCSignature checks the signature of the recipient if the parameter
+// has the preimage of the hash, or the signature of the sender. The lock
+// height of the sender is checked with the transaction by CheckHTLCLock.
+func checkHTLCSignature(program Program, data []byte) error {
+	script, err := ParseHTLCScript(program.Code)
+	if err != nil {
+		return err
+	}
+	signature, preimage, err := ParseHTLCParameter(program.Parameter)
+	if err != nil {
+		return err
+	}
+
+	publicKey := script.Sender
+	if preimage != nil {
+		if sha256.Sum256(preimage) != script.Hash {
+			return errors.New("HTLC preimage does not match the hash")
+		}
+		publicKey = script.Recipient
+	}
+	return crypto.Verify(*publicKey, data, signature[1:])
+}
+
 func checkMultiSigSignatures(program Program, data []byte) error {
 	code := program.Code
 	// Get N parameter
@@ -223,11 +273,11 @@ type byHash []*Program
 func (p byHash) Len() int      { return len(p) }
 func (p byHash) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 func (p byHash) Less(i, j int) bool {
-	hashi, err := crypto.ToProgramHash(p[i].Code)
+	hashi, err := toProgramHash(p[i].Code)
 	if err != nil {
 		panic(p[i].Code)
 	}
-	hashj, err := crypto.ToProgramHash(p[j].Code)
+	hashj, err := toProgramHash(p[j].Code)
 	if err != nil {
 		panic(p[j].Code)
 	}
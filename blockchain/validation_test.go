@@ -231,7 +231,7 @@ func TestRunPrograms(t *testing.T) {
 			break
 		}
 	}
-	err = RunPrograms(data, hashes[index:index+1], programs[index:index+1])
+	err = RunPrograms(data, hashes[index:index+1], programs[index:index+1], 0)
 	assert.NoError(t, err, "[RunProgram] passed with 1 checksig program")
 
 	// 1 loop multisig
@@ -242,25 +242,25 @@ func TestRunPrograms(t *testing.T) {
 			break
 		}
 	}
-	err = RunPrograms(data, hashes[index:index+1], programs[index:index+1])
+	err = RunPrograms(data, hashes[index:index+1], programs[index:index+1], 0)
 	assert.NoError(t, err, "[RunProgram] passed with 1 multisig program")
 
 	// multiple programs
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.NoError(t, err, "[RunProgram] passed with multiple programs")
 
 	// hashes count not equal to programs count
 	init()
 	removeIndex := math.Intn(num)
 	hashes = append(hashes[:removeIndex], hashes[removeIndex+1:]...)
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with unmathed hashes")
 	assert.Equal(t, "The number of data hashes is different with number of programs.", err.Error())
 
 	// With no programs
 	init()
 	programs = []*core.Program{}
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with no programs")
 	assert.Equal(t, "The number of data hashes is different with number of programs.", err.Error())
 
@@ -269,7 +269,7 @@ func TestRunPrograms(t *testing.T) {
 	for i := 0; i < num; i++ {
 		rand.Read(hashes[math.Intn(num)][:])
 	}
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with unmathed hashes")
 	assert.Equal(t, "The data hashes is different with corresponding program code.", err.Error())
 
@@ -277,7 +277,7 @@ func TestRunPrograms(t *testing.T) {
 	init()
 	common.SortProgramHashes(hashes)
 	sort.Sort(sort.Reverse(byHash(programs)))
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with disordered hashes")
 	assert.Equal(t, "The data hashes is different with corresponding program code.", err.Error())
 
@@ -286,7 +286,7 @@ func TestRunPrograms(t *testing.T) {
 	for i := 0; i < num; i++ {
 		programs[math.Intn(num)].Code = nil
 	}
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with random no code")
 	assert.Equal(t, "[ToProgramHash] failed, empty program code", err.Error())
 
@@ -296,13 +296,13 @@ func TestRunPrograms(t *testing.T) {
 		index := math.Intn(num)
 		programs[index].Parameter = nil
 	}
-	err = RunPrograms(data, hashes, programs)
+	err = RunPrograms(data, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with random no parameter")
 
 	t.Log("TestRunPrograms passed")
 }
 
-func newAccount(t *testing.T) *account {
+func newAccount(t testing.TB) *account {
 	a := new(account)
 	var err error
 	a.private, a.public, err = crypto.GenerateKeyPair()
@@ -323,7 +323,7 @@ func newAccount(t *testing.T) *account {
 	return a
 }
 
-func newMultiAccount(num int, t *testing.T) *multiAccount {
+func newMultiAccount(num int, t testing.TB) *multiAccount {
 	ma := new(multiAccount)
 	publicKeys := make([]*crypto.PublicKey, 0, num)
 	for i := 0; i < num; i++ {
@@ -0,0 +1,322 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/config"
+	. "github.com/elastos/Elastos.ELA/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// VersionBitsTopBits is the top bits of the block versions signaling
+	// the deployments, the lower 29 bits are the deployment bits.
+	VersionBitsTopBits = 0x20000000
+
+	// VersionBitsTopMask is the mask of the top bits of the block versions.
+	VersionBitsTopMask = 0xe0000000
+)
+
+// ThresholdState is the state of a deployment for a block, it is the same
+// for the blocks of a retarget window.
+type ThresholdState byte
+
+const (
+	// ThresholdDefined is the state before the start time.
+	ThresholdDefined ThresholdState = iota
+
+	// ThresholdStarted is the state the miners signal the deployment.
+	ThresholdStarted
+
+	// ThresholdLockedIn is the state of the window after enough blocks of
+	// a window signal the deployment.
+	ThresholdLockedIn
+
+	// ThresholdActive is the state the consensus change applies, from the
+	// window after it is locked in.
+	ThresholdActive
+
+	// ThresholdFailed is the state the deployment is not locked in before
+	// the expire time.
+	ThresholdFailed
+)
+
+func (s ThresholdState) String() string {
+	switch s {
+	case ThresholdDefined:
+		return "defined"
+	case ThresholdStarted:
+		return "started"
+	case ThresholdLockedIn:
+		return "lockedin"
+	case ThresholdActive:
+		return "active"
+	case ThresholdFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(s))
+}
+
+// DeploymentInfo is the state of a deployment for the next block of the best
+// chain, and how many blocks of the current window signal it.
+type DeploymentInfo struct {
+	Deployment config.ConsensusDeployment
+	State      ThresholdState
+	Window     uint32
+	Count      uint32
+	Elapsed    uint32
+}
+
+// isSignaling returns if the block version signals the deployment bit.
+func isSignaling(version uint32, bit uint8) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits && version&(1<<bit) != 0
+}
+
+// ancestor returns the ancestor of the node at the height. The nodes in
+// memory are walked back, then the ancestor on the main chain is loaded from
+// the chain store by the block hash of the height, the nodes of a side chain
+// not in memory are loaded until the main chain is reached. The loaded nodes
+// are not linked into the block tree.
+func (bc *Blockchain) ancestor(node *BlockNode, height uint32) (*BlockNode, error) {
+	for node != nil && node.Height > height {
+		if node.Parent == nil && bc.isInMainChain(node) {
+			hash, err := DefaultLedger.Store.GetBlockHash(height)
+			if err != nil {
+				return nil, err
+			}
+			return bc.loadNode(hash)
+		}
+		prevNode, err := bc.prevNode(node)
+		if err != nil {
+			return nil, err
+		}
+		node = prevNode
+	}
+	if node == nil || node.Height != height {
+		return nil, fmt.Errorf("unable to obtain the ancestor block at height %d", height)
+	}
+	return node, nil
+}
+
+// isInMainChain returns if the block of the node is in the main chain of the
+// chain store.
+func (bc *Blockchain) isInMainChain(node *BlockNode) bool {
+	hash, err := DefaultLedger.Store.GetBlockHash(node.Height)
+	return err == nil && hash.IsEqual(*node.Hash)
+}
+
+// loadNode returns the node of the block from memory, or a node not linked
+// into the block tree loaded from the chain store.
+func (bc *Blockchain) loadNode(hash Uint256) (*BlockNode, error) {
+	if node, ok := bc.LookupNodeInIndex(&hash); ok {
+		return node, nil
+	}
+	header, err := bc.GetHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlockNode(header, &hash), nil
+}
+
+// prevNode returns the parent of the node, like GetPrevNodeFromNode, but the
+// parent not in memory is not linked into the block tree.
+func (bc *Blockchain) prevNode(node *BlockNode) (*BlockNode, error) {
+	if node.Parent != nil {
+		return node.Parent, nil
+	}
+	if node.Hash.IsEqual(bc.GenesisHash) {
+		return nil, nil
+	}
+	return bc.loadNode(*node.ParentHash)
+}
+
+// pastMedianTime returns the median time past of the node like
+// CalcPastMedianTime, the previous nodes not in memory are loaded.
+func (bc *Blockchain) pastMedianTime(node *BlockNode) (time.Time, error) {
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	for node != nil {
+		timestamps = append(timestamps, int64(node.Timestamp))
+		if len(timestamps) == medianTimeBlocks {
+			break
+		}
+		prevNode, err := bc.prevNode(node)
+		if err != nil {
+			return time.Time{}, err
+		}
+		node = prevNode
+	}
+	sort.Sort(timeSorter(timestamps))
+	return time.Unix(timestamps[len(timestamps)/2], 0), nil
+}
+
+// countSignals returns the number of the blocks signaling the bit, from the
+// node back to the first block of its window.
+func (bc *Blockchain) countSignals(node *BlockNode, bit uint8) (uint32, error) {
+	count := uint32(0)
+	start := node.Height - node.Height%bc.deploymentWindow
+	for {
+		if isSignaling(node.Version, bit) {
+			count++
+		}
+		if node.Height == start {
+			return count, nil
+		}
+		prevNode, err := bc.prevNode(node)
+		if err != nil {
+			return 0, err
+		}
+		if prevNode == nil {
+			return count, nil
+		}
+		node = prevNode
+	}
+}
+
+// thresholdState returns the state of the deployment for the block after the
+// previous node. The state changes only at the first block of a window, so
+// the states are cached by the last block of the previous windows.
+func (bc *Blockchain) thresholdState(prevNode *BlockNode, index int) (ThresholdState, error) {
+	bc.deploymentLock.Lock()
+	defer bc.deploymentLock.Unlock()
+
+	window := bc.deploymentWindow
+	deployment := bc.deployments[index]
+	cache := bc.deploymentStates[index]
+
+	// the blocks of the first window are defined
+	if prevNode == nil || prevNode.Height+1 < window {
+		return ThresholdDefined, nil
+	}
+
+	// the last block of the previous window
+	prevNode, err := bc.ancestor(prevNode, prevNode.Height-(prevNode.Height+1)%window)
+	if err != nil {
+		return ThresholdDefined, err
+	}
+
+	// walk back to a window of which the state is known
+	state := ThresholdDefined
+	var nodes []*BlockNode
+	for prevNode != nil {
+		if cached, ok := cache[*prevNode.Hash]; ok {
+			state = cached
+			break
+		}
+		medianTime, err := bc.pastMedianTime(prevNode)
+		if err != nil {
+			return ThresholdDefined, err
+		}
+		if medianTime.Unix() < deployment.StartTime {
+			cache[*prevNode.Hash] = ThresholdDefined
+			break
+		}
+		nodes = append(nodes, prevNode)
+		if prevNode.Height+1 < 2*window {
+			break
+		}
+		prevNode, err = bc.ancestor(prevNode, prevNode.Height-window)
+		if err != nil {
+			return ThresholdDefined, err
+		}
+	}
+
+	// and walk forward to compute the states of the following windows
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		pastMedianTime, err := bc.pastMedianTime(node)
+		if err != nil {
+			return ThresholdDefined, err
+		}
+		medianTime := pastMedianTime.Unix()
+		switch state {
+		case ThresholdDefined:
+			if medianTime >= deployment.ExpireTime {
+				state = ThresholdFailed
+			} else if medianTime >= deployment.StartTime {
+				state = ThresholdStarted
+			}
+
+		case ThresholdStarted:
+			if medianTime >= deployment.ExpireTime {
+				state = ThresholdFailed
+				break
+			}
+			count, err := bc.countSignals(node, deployment.Bit)
+			if err != nil {
+				return ThresholdDefined, err
+			}
+			if count >= deployment.Threshold {
+				state = ThresholdLockedIn
+			}
+
+		case ThresholdLockedIn:
+			state = ThresholdActive
+		}
+		cache[*node.Hash] = state
+	}
+	return state, nil
+}
+
+// CalcNextBlockVersion returns the version of the block after the previous
+// node, which signals the deployments started or locked in.
+func (bc *Blockchain) CalcNextBlockVersion(prevNode *BlockNode) (uint32, error) {
+	version := uint32(0)
+	for i, deployment := range bc.deployments {
+		state, err := bc.thresholdState(prevNode, i)
+		if err != nil {
+			return 0, err
+		}
+		if state == ThresholdStarted || state == ThresholdLockedIn {
+			version |= 1 << deployment.Bit
+		}
+	}
+	if version == 0 {
+		return BlockVersion, nil
+	}
+	return VersionBitsTopBits | version, nil
+}
+
+// IsDeploymentActive returns if the consensus change of the deployment applies
+// to the block after the previous node.
+func (bc *Blockchain) IsDeploymentActive(prevNode *BlockNode, name string) (bool, error) {
+	for i, deployment := range bc.deployments {
+		if deployment.Name == name {
+			state, err := bc.thresholdState(prevNode, i)
+			return state == ThresholdActive, err
+		}
+	}
+	return false, fmt.Errorf("unknown deployment %s", name)
+}
+
+// GetDeploymentInfo returns the states of the deployments for the next block
+// of the best chain.
+func (bc *Blockchain) GetDeploymentInfo() ([]DeploymentInfo, error) {
+	bestNode := bc.BestChain
+	infos := make([]DeploymentInfo, 0, len(bc.deployments))
+	for i, deployment := range bc.deployments {
+		state, err := bc.thresholdState(bestNode, i)
+		if err != nil {
+			return nil, err
+		}
+		info := DeploymentInfo{
+			Deployment: deployment,
+			State:      state,
+			Window:     bc.deploymentWindow,
+		}
+		// the blocks of the current window mined so far
+		if bestNode != nil && (bestNode.Height+1)%bc.deploymentWindow != 0 {
+			info.Elapsed = bestNode.Height%bc.deploymentWindow + 1
+			if state == ThresholdStarted {
+				info.Count, err = bc.countSignals(bestNode, deployment.Bit)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
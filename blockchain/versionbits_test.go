@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockchain_ThresholdState(t *testing.T) {
+	bc := NewBlockchain(0)
+	bc.deploymentWindow = 4
+	bc.deployments = []config.ConsensusDeployment{
+		{Name: "first", Bit: 0, StartTime: 1000, ExpireTime: 2000, Threshold: 3},
+		{Name: "second", Bit: 1, StartTime: 1000, ExpireTime: 1050, Threshold: 3},
+	}
+	bc.deploymentStates = []map[common.Uint256]ThresholdState{{}, {}}
+
+	// the blocks are 10 seconds apart from 900
+	var tip *BlockNode
+	addBlocks := func(num int, version uint32) {
+		for i := 0; i < num; i++ {
+			height := uint32(0)
+			if tip != nil {
+				height = tip.Height + 1
+			}
+			hash := common.Uint256{byte(height), byte(height >> 8)}
+			tip = &BlockNode{
+				Hash:      &hash,
+				Height:    height,
+				Version:   version,
+				Timestamp: 900 + height*10,
+				Parent:    tip,
+			}
+		}
+	}
+	state := func(index int) ThresholdState {
+		state, err := bc.thresholdState(tip, index)
+		assert.NoError(t, err)
+		return state
+	}
+	signal := uint32(VersionBitsTopBits | 1)
+
+	// before the start time
+	addBlocks(8, 0)
+	assert.Equal(t, ThresholdDefined, state(0))
+	version, err := bc.CalcNextBlockVersion(tip)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), version)
+
+	// the median time past reaches the start time at the end of the fourth
+	// window
+	addBlocks(8, 0)
+	assert.Equal(t, ThresholdStarted, state(0))
+	assert.Equal(t, ThresholdStarted, state(1))
+	version, err = bc.CalcNextBlockVersion(tip)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(VersionBitsTopBits|3), version)
+
+	// not enough blocks signal in a window
+	addBlocks(2, signal)
+	addBlocks(2, 0)
+	assert.Equal(t, ThresholdStarted, state(0))
+	// the states do not change within a window
+	addBlocks(3, signal)
+	assert.Equal(t, ThresholdStarted, state(0))
+
+	// enough blocks signal in the window, while the second one expires
+	addBlocks(1, 0)
+	assert.Equal(t, ThresholdLockedIn, state(0))
+	assert.Equal(t, ThresholdFailed, state(1))
+	version, err = bc.CalcNextBlockVersion(tip)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(VersionBitsTopBits|1), version)
+
+	// it is active after the locked in window
+	addBlocks(4, 0)
+	assert.Equal(t, ThresholdActive, state(0))
+	active, err := bc.IsDeploymentActive(tip, "first")
+	assert.NoError(t, err)
+	assert.True(t, active)
+	active, err = bc.IsDeploymentActive(tip, "second")
+	assert.NoError(t, err)
+	assert.False(t, active)
+	_, err = bc.IsDeploymentActive(tip, "unknown")
+	assert.Error(t, err)
+
+	// the states are the same computed without the cache
+	bc.deploymentStates = []map[common.Uint256]ThresholdState{{}, {}}
+	assert.Equal(t, ThresholdActive, state(0))
+	assert.Equal(t, ThresholdFailed, state(1))
+
+	// the signals of the current window
+	addBlocks(2, signal)
+	bc.BestChain = tip
+	infos, err := bc.GetDeploymentInfo()
+	if assert.NoError(t, err) && assert.Len(t, infos, 2) {
+		assert.Equal(t, "first", infos[0].Deployment.Name)
+		assert.Equal(t, ThresholdActive, infos[0].State)
+		assert.Equal(t, uint32(2), infos[0].Elapsed)
+	}
+}
+
+func TestIsSignaling(t *testing.T) {
+	assert.True(t, isSignaling(VersionBitsTopBits|1<<28, 28))
+	assert.False(t, isSignaling(VersionBitsTopBits|1<<28, 27))
+	assert.False(t, isSignaling(1<<28, 28))
+	assert.False(t, isSignaling(0xe0000000|1<<28, 28))
+}
+
+func TestBlockchain_Ancestor(t *testing.T) {
+	ledger := DefaultLedger
+	defer func() { DefaultLedger = ledger }()
+
+	store, _ := newGenesisTestStore(t)
+	defer store.Close()
+	if !assert.NoError(t, Init(store)) {
+		return
+	}
+	bc := DefaultLedger.Blockchain
+
+	// the blocks after the genesis block of Init
+	blocks := []*core.Block{nil}
+	previous := bc.GenesisHash
+	for height := uint32(1); height <= 20; height++ {
+		block := &core.Block{
+			Header: core.Header{Height: height, Previous: previous, Timestamp: height},
+			Transactions: []*core.Transaction{
+				NewCoinBaseTransaction(&core.PayloadCoinBase{}, height),
+			},
+		}
+		if !assert.NoError(t, store.persist(block)) {
+			return
+		}
+		blocks = append(blocks, block)
+		previous = block.Hash()
+	}
+	headerLookups := &lookupStore{IStore: store.IStore, prefix: DATA_Header}
+	store.IStore = headerLookups
+	node := func(block *core.Block) *BlockNode {
+		hash := block.Hash()
+		return NewBlockNode(&block.Header, &hash)
+	}
+
+	// the ancestor on the main chain is loaded by the block hash of the
+	// height, not by walking back every block
+	ancestor, err := bc.ancestor(node(blocks[20]), 4)
+	if assert.NoError(t, err) {
+		assert.Equal(t, blocks[4].Hash(), *ancestor.Hash)
+		assert.Equal(t, uint32(4), ancestor.Height)
+	}
+	assert.Equal(t, 1, headerLookups.lookups)
+
+	// the nodes in memory are walked back
+	parent := node(blocks[19])
+	tip := node(blocks[20])
+	tip.Parent = parent
+	headerLookups.lookups = 0
+	ancestor, err = bc.ancestor(tip, 19)
+	assert.NoError(t, err)
+	assert.Equal(t, parent, ancestor)
+	assert.Equal(t, 0, headerLookups.lookups)
+
+	// the side chain is walked back to the main chain
+	side := &core.Block{Header: core.Header{Height: 20, Previous: blocks[19].Hash(), Nonce: 1}}
+	headerLookups.lookups = 0
+	ancestor, err = bc.ancestor(node(side), 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, blocks[10].Hash(), *ancestor.Hash)
+	}
+	assert.Equal(t, 2, headerLookups.lookups)
+
+	// the median time past is computed from the loaded nodes, back to the
+	// genesis block
+	medianTime, err := bc.pastMedianTime(ancestor)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), medianTime.Unix())
+
+	_, err = bc.ancestor(node(blocks[3]), 4)
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elastos/Elastos.ELA/blockchain"
+	"github.com/elastos/Elastos.ELA/config"
+	"github.com/elastos/Elastos.ELA/log"
+)
+
+// commands are the subcommands of the node, such as "ela export -file x",
+// the node runs normally if no subcommand is given.
+var commands = map[string]func(args []string) error{
+	"export":   exportBlocks,
+	"import":   importBlocks,
+	"snapshot": createSnapshot,
+	"restore":  restoreSnapshot,
+	"checkdb":  checkDB,
+	"reindex":  reindex,
+}
+
+func openChain() (blockchain.IChainStore, error) {
+	chainStore, err := blockchain.NewChainStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := blockchain.Init(chainStore); err != nil {
+		chainStore.Close()
+		return nil, err
+	}
+	return chainStore, nil
+}
+
+func exportBlocks(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	file := flags.String("file", "", "the block file to export to")
+	start := flags.Uint("start", 0, "the height of the first block to export")
+	end := flags.Int64("end", -1, "the height of the last block to export, default is the best height")
+	flags.Parse(args)
+	if *file == "" {
+		return errors.New("block file is not specified")
+	}
+
+	chainStore, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	endHeight := chainStore.GetHeight()
+	if *end >= 0 && uint32(*end) < endHeight {
+		endHeight = uint32(*end)
+	}
+	if uint32(*start) > endHeight {
+		return errors.New("start height is higher than end height")
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	count, err := blockchain.ExportBlocks(chainStore, w, config.Parameters.Magic, uint32(*start), endHeight)
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	log.Infof("Exported %d blocks to %s", count, *file)
+	return nil
+}
+
+func importBlocks(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "the block file to import from")
+	trustedHeight := flags.Uint("trustedheight", 0, "do not check transaction signatures of blocks not higher than this height")
+	flags.Parse(args)
+	if *file == "" {
+		return errors.New("block file is not specified")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chainStore, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	count, err := blockchain.DefaultLedger.Blockchain.ImportBlocks(bufio.NewReader(f),
+		config.Parameters.Magic, uint32(*trustedHeight))
+	log.Infof("Imported %d blocks from %s, best height %d", count, *file, chainStore.GetHeight())
+	return err
+}
+
+func createSnapshot(args []string) error {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	file := flags.String("file", "", "the UTXO snapshot file to create at the best height")
+	height := flags.Int64("height", -1, "the height of the snapshot, only the best height is supported, default is the best height")
+	flags.Parse(args)
+	if *file == "" {
+		return errors.New("snapshot file is not specified")
+	}
+
+	chainStore, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	// the store keeps the UTXO set of the best height only
+	if bestHeight := chainStore.GetHeight(); *height >= 0 && uint32(*height) != bestHeight {
+		return fmt.Errorf("snapshot at height %d is not supported, only the best height %d is", *height, bestHeight)
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	snapshotHeight, setHash, err := chainStore.CreateTipUTXOSnapshot(w, config.Parameters.Magic)
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	log.Infof("Created UTXO snapshot %s at height %d, UTXO set hash %s",
+		*file, snapshotHeight, setHash.String())
+	return nil
+}
+
+func restoreSnapshot(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	file := flags.String("file", "", "the UTXO snapshot file to restore from")
+	flags.Parse(args)
+	if *file == "" {
+		return errors.New("snapshot file is not specified")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the snapshot is restored into an empty store, so the store is not
+	// initialized with the genesis block here
+	chainStore, err := blockchain.NewChainStore()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	height, setHash, err := chainStore.RestoreUTXOSnapshot(bufio.NewReader(f), config.Parameters.Magic)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Restored UTXO snapshot %s at height %d, UTXO set hash %s",
+		*file, height, setHash.String())
+	return nil
+}
+
+func checkDB(args []string) error {
+	chainStore, err := blockchain.NewChainStore()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	problems, err := chainStore.CheckDB(config.Parameters.CheckDBDir())
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		log.Error(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in database, run reindex to rebuild the indexes", len(problems))
+	}
+
+	log.Info("Database is consistent")
+	return nil
+}
+
+func reindex(args []string) error {
+	chainStore, err := blockchain.NewChainStore()
+	if err != nil {
+		return err
+	}
+	defer chainStore.Close()
+
+	if err := chainStore.Reindex(); err != nil {
+		return err
+	}
+
+	log.Info("Indexes rebuilt")
+	return nil
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/big"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
@@ -15,6 +20,10 @@ import (
 
 const (
 	DefaultConfigFilename = "./config.json"
+	DefaultDataDir        = "./elastos"
+	DefaultMaxTxPoolSize  = 100 * 1024 * 1024
+	DefaultMaxTxPoolAge   = 336
+	DefaultMaxSigCache    = 100000
 	MINGENBLOCKTIME       = 2
 	DefaultGenBlockTime   = 6
 )
@@ -32,6 +41,11 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		CoinbaseLockTime:   100,
+		TimeLockHeight:     math.MaxUint32,
+		ChainedTxHeight:    math.MaxUint32,
+		HTLCHeight:         math.MaxUint32,
+		Deployments:        []ConsensusDeployment{},
+		Checkpoints:        []Checkpoint{},
 	}
 	testNet = &ChainParams{
 		Name:               "TestNet",
@@ -43,6 +57,11 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		CoinbaseLockTime:   100,
+		TimeLockHeight:     math.MaxUint32,
+		ChainedTxHeight:    math.MaxUint32,
+		HTLCHeight:         math.MaxUint32,
+		Deployments:        []ConsensusDeployment{},
+		Checkpoints:        []Checkpoint{},
 	}
 	regNet = &ChainParams{
 		Name:               "RegNet",
@@ -54,6 +73,12 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		CoinbaseLockTime:   100,
+		TimeLockHeight:     0,
+		ChainedTxHeight:    0,
+		HTLCHeight:         0,
+		Deployments: []ConsensusDeployment{
+			{Name: "testdummy", Bit: 28, StartTime: 0, ExpireTime: math.MaxInt64, Threshold: 8},
+		},
 	}
 )
 
@@ -67,6 +92,11 @@ type PowConfiguration struct {
 
 type Configuration struct {
 	Magic               uint32           `json:"Magic"`
+	DataDir             string           `json:"DataDir"`
+	PruneBlocks         uint32           `json:"PruneBlocks"`
+	MaxTxPoolSize       int              `json:"MaxTxPoolSize"`
+	MaxTxPoolAge        int              `json:"MaxTxPoolAge"`
+	MaxSigCache         int              `json:"MaxSigCache"`
 	FoundationAddress   string           `json:"FoundationAddress"`
 	Version             int              `json:"Version"`
 	SeedList            []string         `json:"SeedList"`
@@ -96,12 +126,74 @@ type Configuration struct {
 	MaxBlockSize        int              `json:"MaxBlockSize"`
 	PowConfiguration    PowConfiguration `json:"PowConfiguration"`
 	Arbiters            []string         `json:"Arbiters"`
+	Checkpoints         []CheckpointJSON `json:"Checkpoints"`
 }
 
 type ConfigFile struct {
 	ConfigFile Configuration `json:"Configuration"`
 }
 
+// Checkpoint is a known good block of the network, the block at the height
+// must have the hash, and the chain can not fork below it.
+type Checkpoint struct {
+	Height uint32
+	Hash   common.Uint256
+}
+
+// CheckpointJSON is a checkpoint set in config.json in addition to the
+// checkpoints of the network, the hash is in the reversed hex form returned
+// by getblockhash.
+type CheckpointJSON struct {
+	Height uint32 `json:"Height"`
+	Hash   string `json:"Hash"`
+}
+
+// MergeCheckpoints returns the checkpoints of the network with the ones set
+// in config.json in height order, two checkpoints at the same height must
+// have the same hash.
+func MergeCheckpoints(checkpoints []Checkpoint, extra []CheckpointJSON) ([]Checkpoint, error) {
+	merged := append([]Checkpoint{}, checkpoints...)
+	for _, c := range extra {
+		hashBytes, err := common.HexStringToBytes(c.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash of checkpoint at height %d: %s", c.Height, err)
+		}
+		hash, err := common.Uint256FromBytes(common.BytesReverse(hashBytes))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash of checkpoint at height %d: %s", c.Height, err)
+		}
+		merged = append(merged, Checkpoint{Height: c.Height, Hash: *hash})
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Height < merged[j].Height })
+	unique := merged[:0]
+	for _, c := range merged {
+		if n := len(unique); n > 0 && unique[n-1].Height == c.Height {
+			if !unique[n-1].Hash.IsEqual(c.Hash) {
+				return nil, fmt.Errorf("conflicting checkpoints at height %d", c.Height)
+			}
+			continue
+		}
+		unique = append(unique, c)
+	}
+	return unique, nil
+}
+
+// ConsensusDeployment is a consensus change activated by the miners setting
+// the bit of the block versions. The miners signal it after the start time,
+// and it is locked in when enough blocks of a retarget window signal it, or
+// fails if it is not locked in before the expire time. The times are Unix
+// timestamps compared with the median time past.
+type ConsensusDeployment struct {
+	Name       string
+	Bit        uint8
+	StartTime  int64
+	ExpireTime int64
+	// Threshold is the number of the blocks in a retarget window signaling
+	// the deployment to lock it in.
+	Threshold uint32
+}
+
 type ChainParams struct {
 	Name               string
 	PowLimit           *big.Int
@@ -112,6 +204,30 @@ type ChainParams struct {
 	MaxOrphanBlocks    int
 	MinMemoryNodes     uint32
 	CoinbaseLockTime   uint32
+
+	// TimeLockHeight is the height from which the lock times and output
+	// locks not below 500000000 are Unix timestamps compared with the median
+	// time past, the max height if it is not scheduled yet.
+	TimeLockHeight uint32
+
+	// ChainedTxHeight is the height from which a transaction in a block can
+	// spend the outputs of the previous transactions in the block, the max
+	// height if it is not scheduled yet.
+	ChainedTxHeight uint32
+
+	// HTLCHeight is the height from which the outputs can be paid to the
+	// HTLC programs and the HTLC programs can be run, the max height if it
+	// is not scheduled yet.
+	HTLCHeight uint32
+
+	// Deployments are the consensus changes activated by the miners, the
+	// bits must be different from each other and lower than 29.
+	Deployments []ConsensusDeployment
+
+	// Checkpoints are taken from the best chain of the network at release
+	// time, they must be in height order. The ones set in config.json are
+	// merged at start up.
+	Checkpoints []Checkpoint
 }
 
 type configParams struct {
@@ -119,8 +235,34 @@ type configParams struct {
 	ChainParam *ChainParams
 }
 
+// ConfigFileFlag is the command line flag of the config file. The config is
+// loaded when the package is initialized, before the flags are parsed, so
+// the flag is looked up in the arguments here.
+const ConfigFileFlag = "config"
+
+// configFilename returns the config file given by the config flag in args,
+// or the default one in the working directory.
+func configFilename(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == ConfigFileFlag && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, ConfigFileFlag+"=") {
+			return name[len(ConfigFileFlag)+1:]
+		}
+	}
+	return DefaultConfigFilename
+}
+
 func init() {
-	file, e := ioutil.ReadFile(DefaultConfigFilename)
+	file, e := ioutil.ReadFile(configFilename(os.Args[1:]))
 	if e != nil {
 		log.Fatalf("File error: %v\n", e)
 		os.Exit(1)
@@ -136,13 +278,87 @@ func init() {
 	}
 	//	Parameters = &(config.ConfigFile)
 	Parameters.Configuration = &config.ConfigFile
-	if Parameters.PowConfiguration.ActiveNet == "MainNet" {
-		Parameters.ChainParam = mainNet
-	} else if Parameters.PowConfiguration.ActiveNet == "TestNet" {
-		Parameters.ChainParam = testNet
-	} else if Parameters.PowConfiguration.ActiveNet == "RegNet" {
-		Parameters.ChainParam = regNet
+	Parameters.ChainParam = GetChainParams(Parameters.PowConfiguration.ActiveNet)
+	if Parameters.ChainParam != nil {
+		checkpoints, err := MergeCheckpoints(Parameters.ChainParam.Checkpoints, config.ConfigFile.Checkpoints)
+		if err != nil {
+			log.Fatalf("Checkpoints error %v", err)
+			os.Exit(1)
+		}
+		Parameters.ChainParam.Checkpoints = checkpoints
+	}
+}
+
+// GetChainParams returns the parameters of the network with the given name,
+// or nil if there is no such network.
+func GetChainParams(activeNet string) *ChainParams {
+	switch activeNet {
+	case "MainNet":
+		return mainNet
+	case "TestNet":
+		return testNet
+	case "RegNet":
+		return regNet
+	}
+	return nil
+}
+
+// NetworkDir returns the directory of the active network under the data
+// directory, so the data of different networks never collide.
+func (p *configParams) NetworkDir() string {
+	dataDir := p.DataDir
+	if dataDir == "" {
+		dataDir = DefaultDataDir
+	}
+	return filepath.Join(dataDir, p.ChainParam.Name)
+}
+
+// ChainDir returns the directory of the chain store.
+func (p *configParams) ChainDir() string {
+	return filepath.Join(p.NetworkDir(), "chain")
+}
+
+// CheckDBDir returns the directory of the scratch store used by the checkdb
+// command, it is deleted after the check.
+func (p *configParams) CheckDBDir() string {
+	return filepath.Join(p.NetworkDir(), "checkdb")
+}
+
+// LogDir returns the directory of the log files.
+func (p *configParams) LogDir() string {
+	return filepath.Join(p.NetworkDir(), "logs")
+}
+
+// TxPoolFile returns the file the transaction pool is saved to.
+func (p *configParams) TxPoolFile() string {
+	return filepath.Join(p.NetworkDir(), "txpool.dat")
+}
+
+// TxPoolSize returns the max total size in bytes of the transactions in the
+// transaction pool.
+func (p *configParams) TxPoolSize() int {
+	if p.MaxTxPoolSize <= 0 {
+		return DefaultMaxTxPoolSize
+	}
+	return p.MaxTxPoolSize
+}
+
+// TxPoolAge returns how long a transaction is kept in the transaction pool
+// before it expires.
+func (p *configParams) TxPoolAge() time.Duration {
+	if p.MaxTxPoolAge <= 0 {
+		return DefaultMaxTxPoolAge * time.Hour
+	}
+	return time.Duration(p.MaxTxPoolAge) * time.Hour
+}
+
+// SigCacheEntries returns the max number of the verified signatures kept in
+// the signature cache.
+func (p *configParams) SigCacheEntries() int {
+	if p.MaxSigCache <= 0 {
+		return DefaultMaxSigCache
 	}
+	return p.MaxSigCache
 }
 
 func (config *Configuration) GetArbitrators() ([][]byte, error) {
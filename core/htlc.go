@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// HTLC is the type of the hash time-locked contract programs, which are
+	// spent by the recipient with the preimage of the hash, or by the sender
+	// after the lock height.
+	HTLC = 0xB0
+
+	// PrefixHTLC is the prefix of the program hashes of the HTLC programs.
+	PrefixHTLC = 0x1C
+
+	// HTLCCodeLength is the length of the HTLC program codes, which are
+	// the recipient public key, the sender public key, the hash and the lock
+	// height, each with the length byte, and the HTLC type byte.
+	HTLCCodeLength = 107
+
+	// MaxHTLCPreimageLength is the max length of the preimage of the hash.
+	MaxHTLCPreimageLength = 75
+)
+
+// HTLCScript is the content of an HTLC program code.
+type HTLCScript struct {
+	Recipient  *crypto.PublicKey
+	Sender     *crypto.PublicKey
+	Hash       Uint256
+	LockHeight uint32
+}
+
+// CreateHTLCRedeemScript returns the HTLC program code, the hash is the
+// SHA-256 hash of the preimage, and the lock height is the block height lower
+// than which the sender can not spend it.
+func CreateHTLCRedeemScript(script *HTLCScript) ([]byte, error) {
+	recipient, err := script.Recipient.EncodePoint(true)
+	if err != nil {
+		return nil, errors.New("create HTLC redeem script, encode recipient public key failed")
+	}
+	sender, err := script.Sender.EncodePoint(true)
+	if err != nil {
+		return nil, errors.New("create HTLC redeem script, encode sender public key failed")
+	}
+	lockHeight := make([]byte, 4)
+	binary.LittleEndian.PutUint32(lockHeight, script.LockHeight)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(len(recipient)))
+	buf.Write(recipient)
+	buf.WriteByte(byte(len(sender)))
+	buf.Write(sender)
+	buf.WriteByte(byte(len(script.Hash)))
+	buf.Write(script.Hash[:])
+	buf.WriteByte(byte(len(lockHeight)))
+	buf.Write(lockHeight)
+	buf.WriteByte(HTLC)
+	return buf.Bytes(), nil
+}
+
+// IsHTLCCode returns if the program code is an HTLC program code.
+func IsHTLCCode(code []byte) bool {
+	return len(code) == HTLCCodeLength && code[len(code)-1] == HTLC
+}
+
+// ParseHTLCScript returns the content of the HTLC program code.
+func ParseHTLCScript(code []byte) (*HTLCScript, error) {
+	if !IsHTLCCode(code) {
+		return nil, errors.New("not a valid HTLC code, length or type not match")
+	}
+	if code[0] != 33 || code[34] != 33 || code[68] != 32 || code[101] != 4 {
+		return nil, errors.New("not a valid HTLC code, content length not match")
+	}
+
+	recipient, err := crypto.DecodePoint(code[1:34])
+	if err != nil {
+		return nil, err
+	}
+	sender, err := crypto.DecodePoint(code[35:68])
+	if err != nil {
+		return nil, err
+	}
+	script := &HTLCScript{
+		Recipient:  recipient,
+		Sender:     sender,
+		LockHeight: binary.LittleEndian.Uint32(code[102:106]),
+	}
+	copy(script.Hash[:], code[69:101])
+	return script, nil
+}
+
+// ToHTLCProgramHash returns the program hash of the HTLC program code, which
+// is the address the HTLC outputs are paid to.
+func ToHTLCProgramHash(code []byte) (*Uint168, error) {
+	if !IsHTLCCode(code) {
+		return nil, errors.New("[ToHTLCProgramHash] error, not a valid HTLC code")
+	}
+	hash := sha256.Sum256(code)
+	md160 := ripemd160.New()
+	md160.Write(hash[:])
+	return Uint168FromBytes(md160.Sum([]byte{PrefixHTLC}))
+}
+
+// ParseHTLCParameter returns the signature and the preimage of the HTLC
+// program parameter. The parameter of the recipient is the signature followed
+// by the preimage with the length byte, and the parameter of the sender is
+// the signature only, of which the preimage is nil.
+func ParseHTLCParameter(parameter []byte) (signature, preimage []byte, err error) {
+	if len(parameter) < crypto.SignatureScriptLength {
+		return nil, nil, errors.New("invalid HTLC parameter, signature length not enough")
+	}
+	signature = parameter[:crypto.SignatureScriptLength]
+	if len(parameter) == crypto.SignatureScriptLength {
+		return signature, nil, nil
+	}
+
+	preimage = parameter[crypto.SignatureScriptLength+1:]
+	if len(preimage) == 0 || len(preimage) > MaxHTLCPreimageLength ||
+		int(parameter[crypto.SignatureScriptLength]) != len(preimage) {
+		return nil, nil, errors.New("invalid HTLC parameter, preimage length not match")
+	}
+	return signature, preimage, nil
+}
@@ -22,6 +22,10 @@ const (
 	ErrIneffectiveCoinbase   ErrCode = 45018
 	ErrUTXOLocked            ErrCode = 45019
 	ErrSideChainPowConsensus ErrCode = 45020
+	ErrInsufficientFee       ErrCode = 45021
+	ErrTxPoolFull            ErrCode = 45022
+	ErrTxChainTooLong        ErrCode = 45023
+	ErrOrphanLimit           ErrCode = 45024
 
 	SessionExpired       ErrCode = 41001
 	IllegalDataFormat    ErrCode = 41003
@@ -54,6 +58,10 @@ var ErrMap = map[ErrCode]string{
 	InternalError:            "Internal error",
 	ErrUTXOLocked:            "Error utxo locked",
 	ErrSideChainPowConsensus: "Error sidechain pow consensus",
+	ErrInsufficientFee:       "Error fee lower than the transaction pool minimum fee",
+	ErrTxPoolFull:            "Error transaction pool is full",
+	ErrTxChainTooLong:        "Error too many unconfirmed ancestors or descendants",
+	ErrOrphanLimit:           "Error too many orphan transactions from the peer",
 	ErrInvalidInput:          "INTERNAL ERROR, ErrInvalidInput",
 	ErrInvalidOutput:         "INTERNAL ERROR, ErrInvalidOutput",
 	ErrAssetPrecision:        "INTERNAL ERROR, ErrAssetPrecision",
@@ -25,6 +25,10 @@ func TestErrCode_Message(t *testing.T) {
 		ErrIneffectiveCoinbase,
 		ErrUTXOLocked,
 		ErrSideChainPowConsensus,
+		ErrInsufficientFee,
+		ErrTxPoolFull,
+		ErrTxChainTooLong,
+		ErrOrphanLimit,
 		SessionExpired,
 		IllegalDataFormat,
 		PowServiceNotStarted,
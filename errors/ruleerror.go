@@ -0,0 +1,19 @@
+package errors
+
+import "fmt"
+
+// RuleError is the rule a transaction fails to pass, with the error code
+// returned to the clients and the reason.
+type RuleError struct {
+	Code ErrCode
+	Rule string
+	Err  error
+}
+
+func NewRuleError(code ErrCode, rule string, err error) *RuleError {
+	return &RuleError{Code: code, Rule: rule, Err: err}
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("[%s], %s", e.Rule, e.Err)
+}
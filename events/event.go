@@ -19,6 +19,8 @@ const (
 	EventNodeDisconnect          EventType = 4
 	EventRollbackTransaction     EventType = 5
 	EventNewTransactionPutInPool EventType = 6
+	EventTransactionReplaced     EventType = 7
+	EventTransactionExpired      EventType = 8
 )
 
 type Event struct {
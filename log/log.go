@@ -52,10 +52,12 @@ var (
 		traceLog: Color(Pink, "[TRACE]"),
 	}
 	Stdout = os.Stdout
+
+	// OutputPath is the log files output path, it should be set before Init.
+	OutputPath = "./Logs/"
 )
 
 const (
-	OutputPath           = "./Logs/" // The log files output path
 	namePrefix           = "LEVEL"
 	callDepth            = 2
 	KB_SIZE              = int64(1024)
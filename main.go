@@ -1,8 +1,13 @@
 package main
 
 import (
+	"flag"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA/blockchain"
@@ -22,7 +27,10 @@ const (
 	DefaultMultiCoreNum = 4
 )
 
-func init() {
+// setup initializes the logs and global settings after the flags are
+// parsed, the log files are placed in the data directory.
+func setup() {
+	log.OutputPath = config.Parameters.LogDir() + string(filepath.Separator)
 	log.Init(
 		config.Parameters.PrintLevel,
 		config.Parameters.MaxPerLogSize,
@@ -48,6 +56,12 @@ func init() {
 	}
 	blockchain.FoundationAddress = *address
 
+	// older versions put the chain store in the working directory
+	if _, err := os.Stat("Chain"); err == nil {
+		log.Warnf("Found the chain store of an older version in ./Chain, "+
+			"move it to %s to keep the synced blocks", config.Parameters.ChainDir())
+	}
+
 	runtime.GOMAXPROCS(coreNum)
 }
 
@@ -59,20 +73,56 @@ func startConsensus() {
 	}
 }
 
+// waitForInterrupt blocks until the process is interrupted or terminated.
+func waitForInterrupt() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+}
+
+// saveTxPool saves the transaction pool to file, so the transactions are
+// reloaded after restart.
+func saveTxPool(noder protocol.Noder) {
+	count, err := noder.SaveTxPool(config.Parameters.TxPoolFile())
+	if err != nil {
+		log.Error("Save transaction pool failed,", err)
+		return
+	}
+	log.Infof("Saved %d transactions in pool", count)
+}
+
 func main() {
 	//var blockChain *ledger.Blockchain
 	var err error
 	var noder protocol.Noder
+	// the config file is loaded by the config package with this flag
+	flag.String(config.ConfigFileFlag, config.DefaultConfigFilename, "the config file")
+	dataDir := flag.String("datadir", "", "the data directory, the data of each network is placed in a subdirectory named by the network")
+	flag.Parse()
+	if *dataDir != "" {
+		config.Parameters.DataDir = *dataDir
+	}
+	setup()
+
+	if args := flag.Args(); len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			if err = command(args[1:]); err != nil {
+				log.Error(err)
+				os.Exit(-1)
+			}
+			return
+		}
+	}
 	log.Trace("Node version: ", config.Version)
 	log.Info("1. BlockChain init")
 	chainStore, err := blockchain.NewChainStore()
 	if err != nil {
 		goto ERROR
 	}
-	defer chainStore.Close()
 
 	err = blockchain.Init(chainStore)
 	if err != nil {
+		chainStore.Close()
 		goto ERROR
 	}
 
@@ -81,6 +131,19 @@ func main() {
 
 	servers.ServerNode = noder
 
+	if count, err := noder.LoadTxPool(config.Parameters.TxPoolFile()); err != nil {
+		log.Error("Load transaction pool failed,", err)
+	} else {
+		log.Infof("Loaded %d transactions to pool", count)
+	}
+	go func() {
+		ticker := time.NewTicker(blockchain.TxPoolSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			saveTxPool(noder)
+		}
+	}()
+
 	log.Info("3. --Start the RPC service")
 	go httpjsonrpc.StartRPCServer()
 
@@ -91,7 +154,13 @@ func main() {
 		go httpnodeinfo.StartServer()
 	}
 	startConsensus()
-	select {}
+
+	waitForInterrupt()
+	log.Info("Shutting down")
+	saveTxPool(noder)
+	// the store is closed after the block being persisted is written
+	chainStore.Close()
+	return
 ERROR:
 	log.Error(err)
 	os.Exit(-1)
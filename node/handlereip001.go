@@ -226,6 +226,11 @@ func (h *HandlerEIP001) onGetData(getData *msg.GetData) error {
 	for _, iv := range getData.InvList {
 		switch iv.Type {
 		case msg.InvTypeBlock:
+			if chain.DefaultLedger.Store.IsBlockPruned(iv.Hash) {
+				log.Debug("Block ", iv.Hash, " is pruned, send not found message")
+				notFound.AddInvVect(iv)
+				continue
+			}
 			block, err := chain.DefaultLedger.Store.GetBlock(iv.Hash)
 			if err != nil {
 				log.Debug("Can't get block from hash: ", iv.Hash, " ,send not found message")
@@ -258,6 +263,11 @@ func (h *HandlerEIP001) onGetData(getData *msg.GetData) error {
 				return nil
 			}
 
+			if chain.DefaultLedger.Store.IsBlockPruned(iv.Hash) {
+				log.Debug("Block ", iv.Hash, " is pruned, send not found message")
+				notFound.AddInvVect(iv)
+				continue
+			}
 			block, err := chain.DefaultLedger.Store.GetBlock(iv.Hash)
 			if err != nil {
 				log.Debug("Can't get block from hash: ", iv.Hash, " ,send not found message")
@@ -348,15 +358,19 @@ func (h *HandlerEIP001) onTx(msgTx *msg.Tx) error {
 		return fmt.Errorf("[HandlerEIP001] Transaction already exsisted")
 	}
 
-	if errCode := LocalNode.AppendToTxnPool(tx); errCode != errors.Success {
+	accepted, errCode := LocalNode.ProcessTransaction(tx, true, node.ID())
+	if errCode != errors.Success {
 		reject := msg.NewReject(msgTx.CMD(), msg.RejectInvalid, errCode.Message())
 		reject.Hash = tx.Hash()
 		node.Send(reject)
 		return fmt.Errorf("[HandlerEIP001] VerifyTransaction failed when AppendToTxnPool")
 	}
 
-	LocalNode.Relay(node, tx)
-	log.Infof("Relay Transaction type %s hash %s", tx.TxType.Name(), tx.Hash().String())
+	// the orphan transactions are relayed once their parents arrive
+	for _, tx := range accepted {
+		LocalNode.Relay(node, tx)
+		log.Infof("Relay Transaction type %s hash %s", tx.TxType.Name(), tx.Hash().String())
+	}
 	LocalNode.IncRxTxnCnt()
 
 	return nil
@@ -231,11 +231,15 @@ func (h *HandlerV0) onTx(msgTx *msg.Tx) error {
 	tx := msgTx.Transaction.(*core.Transaction)
 
 	if !LocalNode.ExistedID(tx.Hash()) && !LocalNode.IsSyncHeaders() {
-		if errCode := LocalNode.AppendToTxnPool(tx); errCode != errors.Success {
+		accepted, errCode := LocalNode.ProcessTransaction(tx, true, node.ID())
+		if errCode != errors.Success {
 			return fmt.Errorf("[HandlerBase] VerifyTransaction failed when AppendToTxnPool")
 		}
-		LocalNode.Relay(node, tx)
-		log.Debugf("Relay Transaction hash %s type %s", tx.Hash().String(), tx.TxType.Name())
+		// the orphan transactions are relayed once their parents arrive
+		for _, tx := range accepted {
+			LocalNode.Relay(node, tx)
+			log.Debugf("Relay Transaction hash %s type %s", tx.Hash().String(), tx.TxType.Name())
+		}
 		LocalNode.IncRxTxnCnt()
 	}
 
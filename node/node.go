@@ -116,6 +116,10 @@ func InitLocalNode() protocol.Noder {
 	if Parameters.OpenService {
 		LocalNode.services += protocol.OpenService
 	}
+	// a pruned node can not serve the full data of old blocks
+	if Parameters.PruneBlocks > 0 || chain.DefaultLedger.Store.GetPrunedHeight() > 0 {
+		LocalNode.services += protocol.PrunedService
+	}
 	LocalNode.relay = true
 	idHash := sha256.Sum256([]byte(strconv.Itoa(int(time.Now().UnixNano()))))
 	binary.Read(bytes.NewBuffer(idHash[:8]), binary.LittleEndian, &(LocalNode.id))
@@ -182,6 +186,7 @@ func (node *node) NodeDisconnect(v interface{}) {
 		log.Debugf("Node [0x%x] disconnected", n.ID())
 		n.SetState(p2p.INACTIVITY)
 		n.GetConn().Close()
+		node.RemoveOrphansByPeer(n.ID())
 	}
 }
 
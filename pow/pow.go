@@ -123,8 +123,14 @@ func (pow *PowService) GenerateBlock(minerAddr string) (*Block, error) {
 		return nil, err
 	}
 
+	// signal the deployments started or locked in
+	version, err := DefaultLedger.Blockchain.CalcNextBlockVersion(DefaultLedger.Blockchain.BestChain)
+	if err != nil {
+		return nil, err
+	}
+
 	header := Header{
-		Version:    0,
+		Version:    version,
 		Previous:   *DefaultLedger.Blockchain.BestChain.Hash,
 		MerkleRoot: common.EmptyHash,
 		Timestamp:  uint32(DefaultLedger.Blockchain.MedianAdjustedTime().Unix()),
@@ -150,29 +156,59 @@ func (pow *PowService) GenerateBlock(minerAddr string) (*Block, error) {
 	}
 	sort.Sort(txsByFeeDesc)
 
-	for _, tx := range txsByFeeDesc {
-		totalTxsSize = totalTxsSize + tx.GetSize()
-		if totalTxsSize > config.Parameters.MaxBlockSize {
-			break
-		}
-		if txCount >= config.Parameters.MaxTxsInBlock {
-			break
-		}
+	// a transaction spending the outputs of other transactions in pool is
+	// deferred until they are included, so it is after them in the block.
+	// It waits for the next blocks until the chained transactions are
+	// activated.
+	chained := IsChainedTxActive(nextBlockHeight)
+	included := make(TxMap)
+	pending := txsByFeeDesc
+fill:
+	for len(pending) > 0 {
+		var deferred []*Transaction
+	next:
+		for _, tx := range pending {
+			if totalTxsSize+tx.GetSize() > config.Parameters.MaxBlockSize {
+				break fill
+			}
+			if txCount >= config.Parameters.MaxTxsInBlock {
+				break fill
+			}
 
-		if !IsFinalizedTransaction(tx, nextBlockHeight) {
-			continue
-		}
-		if errCode := CheckTransactionContext(tx); errCode != Success {
-			log.Warn("check transaction context failed, wrong transaction:", tx.Hash().String())
-			continue
+			if !IsFinalizedTransaction(tx, nextBlockHeight, DefaultLedger.Blockchain.MedianTimePast) {
+				continue
+			}
+			for _, input := range tx.Inputs {
+				txId := input.Previous.TxID
+				if _, ok := txsInPool[txId]; !ok {
+					continue
+				}
+				if !chained {
+					continue next
+				}
+				if included[txId] == nil {
+					deferred = append(deferred, tx)
+					continue next
+				}
+			}
+			if errCode := CheckChainedTransactionContext(tx, included); errCode != Success {
+				log.Warn("check transaction context failed, wrong transaction:", tx.Hash().String())
+				continue
+			}
+			fee := GetChainedTxFee(tx, DefaultLedger.Blockchain.AssetID, included)
+			if fee != tx.Fee {
+				continue
+			}
+			msgBlock.Transactions = append(msgBlock.Transactions, tx)
+			included[tx.Hash()] = tx
+			totalTxsSize += tx.GetSize()
+			totalTxFee += fee
+			txCount++
 		}
-		fee := GetTxFee(tx, DefaultLedger.Blockchain.AssetID)
-		if fee != tx.Fee {
-			continue
+		if len(deferred) == len(pending) {
+			break
 		}
-		msgBlock.Transactions = append(msgBlock.Transactions, tx)
-		totalTxFee += fee
-		txCount++
+		pending = deferred
 	}
 
 	blockReward := RewardAmountPerBlock
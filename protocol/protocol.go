@@ -27,7 +27,8 @@ const (
 )
 
 const (
-	OpenService = 1 << 2
+	OpenService   = 1 << 2
+	PrunedService = 1 << 3
 )
 
 type Noder interface {
@@ -55,7 +56,16 @@ type Noder interface {
 	GetConnectionCount() (uint, uint)
 	GetTransactionPool(bool) map[common.Uint256]*core.Transaction
 	AppendToTxnPool(*core.Transaction) errors.ErrCode
+	TestAcceptTransaction(txn *core.Transaction) (common.Fixed64, *errors.RuleError)
+	ProcessTransaction(txn *core.Transaction, allowOrphan bool, peer uint64) ([]*core.Transaction, errors.ErrCode)
 	IsDuplicateSidechainTx(sidechainTxHash common.Uint256) bool
+	GetTransactionCount() int
+	GetTxPoolSize() int
+	GetMinFeePerKB() common.Fixed64
+	GetOrphanCount() int
+	EstimateFee(target uint32) (common.Fixed64, error)
+	SaveTxPool(path string) (int, error)
+	LoadTxPool(path string) (int, error)
 	ExistedID(id common.Uint256) bool
 	RequireNeighbourList()
 	UpdateInfo(t time.Time, version uint32, services uint64,
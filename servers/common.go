@@ -27,8 +27,19 @@ type OutputInfo struct {
 }
 
 type ProgramInfo struct {
-	Code      string `json:"code"`
-	Parameter string `json:"parameter"`
+	Code      string    `json:"code"`
+	Parameter string    `json:"parameter"`
+	HTLC      *HTLCInfo `json:"htlc,omitempty"`
+}
+
+type HTLCInfo struct {
+	Recipient  string `json:"recipient"`
+	Sender     string `json:"sender"`
+	Hash       string `json:"hash"`
+	LockHeight uint32 `json:"lockheight"`
+	Address    string `json:"address"`
+	Code       string `json:"code,omitempty"`
+	Preimage   string `json:"preimage,omitempty"`
 }
 
 type TransactionInfo struct {
@@ -148,3 +159,86 @@ type UTXOInfo struct {
 	Confirmations uint32 `json:"confirmations"`
 	OutputLock    uint32 `json:"outputlock"`
 }
+
+type AddressTxInfo struct {
+	Txid          string `json:"txid"`
+	Height        uint32 `json:"height"`
+	Confirmations uint32 `json:"confirmations"`
+}
+
+type AddressTxsInfo struct {
+	Transactions []AddressTxInfo `json:"transactions"`
+}
+
+type TxPoolInfo struct {
+	Size     int    `json:"size"`
+	Bytes    int    `json:"bytes"`
+	MaxBytes int    `json:"maxbytes"`
+	MinFee   string `json:"minfee"`
+	Orphans  int    `json:"orphans"`
+}
+
+type TxAcceptInfo struct {
+	Txid         string `json:"txid"`
+	Allowed      bool   `json:"allowed"`
+	Size         int    `json:"size"`
+	Fee          string `json:"fee"`
+	FeePerKB     string `json:"feeperkb"`
+	RejectCode   int    `json:"rejectcode,omitempty"`
+	RejectRule   string `json:"rejectrule,omitempty"`
+	RejectReason string `json:"rejectreason,omitempty"`
+}
+
+type FeeEstimateInfo struct {
+	FeePerKB string `json:"feeperkb"`
+	Blocks   uint32 `json:"blocks"`
+}
+
+type SigCacheInfo struct {
+	Entries    int     `json:"entries"`
+	MaxEntries int     `json:"maxentries"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	HitRate    float64 `json:"hitrate"`
+}
+
+type DeploymentInfo struct {
+	Name       string `json:"name"`
+	Bit        uint8  `json:"bit"`
+	StartTime  int64  `json:"starttime"`
+	ExpireTime int64  `json:"expiretime"`
+	Threshold  uint32 `json:"threshold"`
+	Window     uint32 `json:"window"`
+	State      string `json:"state"`
+	Count      uint32 `json:"count"`
+	Elapsed    uint32 `json:"elapsed"`
+}
+
+type TxReplacementInfo struct {
+	Txid     string   `json:"txid"`
+	Replaced []string `json:"replaced"`
+}
+
+type CheckpointInfo struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+type SpenderInfo struct {
+	Txid          string `json:"txid"`
+	Height        uint32 `json:"height"`
+	Confirmations uint32 `json:"confirmations"`
+}
+
+type TxOutInfo struct {
+	Txid          string       `json:"txid"`
+	VOut          uint16       `json:"vout"`
+	AssetId       string       `json:"assetid"`
+	Address       string       `json:"address"`
+	Amount        string       `json:"amount"`
+	OutputLock    uint32       `json:"outputlock"`
+	Height        uint32       `json:"height"`
+	Confirmations uint32       `json:"confirmations"`
+	Spent         bool         `json:"spent"`
+	SpentBy       *SpenderInfo `json:"spentby"`
+}
@@ -37,6 +37,12 @@ func StartRPCServer() {
 	mainMux["getblockhash"] = GetBlockHash
 	mainMux["getconnectioncount"] = GetConnectionCount
 	mainMux["getrawmempool"] = GetTransactionPool
+	mainMux["getmempoolinfo"] = GetTxPoolInfo
+	mainMux["estimatefee"] = EstimateFee
+	mainMux["getsigcacheinfo"] = GetSigCacheInfo
+	mainMux["getdeploymentinfo"] = GetDeploymentInfo
+	mainMux["testmempoolaccept"] = TestTxPoolAccept
+	mainMux["createhtlc"] = CreateHTLC
 	mainMux["getrawtransaction"] = GetRawTransaction
 	mainMux["getneighbors"] = GetNeighbors
 	mainMux["getnodestate"] = GetNodeState
@@ -48,6 +54,8 @@ func StartRPCServer() {
 	mainMux["getexistwithdrawtransactions"] = GetExistWithdrawTransactions
 	mainMux["listunspent"] = ListUnspent
 	mainMux["getreceivedbyaddress"] = GetReceivedByAddress
+	mainMux["gettransactionsbyaddress"] = GetTransactionsByAddress
+	mainMux["gettxout"] = GetTxOut
 	// aux interfaces
 	mainMux["help"] = AuxHelp
 	mainMux["submitauxblock"] = SubmitAuxBlock
@@ -176,12 +184,20 @@ func convertParams(method string, params []interface{}) Params {
 		return FromArray(params, "mine")
 	case "discretemining":
 		return FromArray(params, "count")
-	case "sendrawtransaction":
+	case "sendrawtransaction", "testmempoolaccept":
 		return FromArray(params, "data")
 	case "listunspent":
 		return FromArray(params, "addresses")
 	case "getreceivedbyaddress":
 		return FromArray(params, "address")
+	case "gettransactionsbyaddress":
+		return FromArray(params, "address", "skip", "limit")
+	case "gettxout":
+		return FromArray(params, "txid", "vout")
+	case "estimatefee":
+		return FromArray(params, "blocks")
+	case "createhtlc":
+		return FromArray(params, "recipient", "sender", "hash", "lockheight")
 	default:
 		return Params{}
 	}
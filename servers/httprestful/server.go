@@ -31,8 +31,13 @@ const (
 	Api_GetBalancebyAsset   = "/api/v1/asset/balance/:addr/:assetid"
 	Api_GetUTXObyAsset      = "/api/v1/asset/utxo/:addr/:assetid"
 	Api_GetUTXObyAddr       = "/api/v1/asset/utxos/:addr"
+	Api_GetTxsByAddr        = "/api/v1/address/transactions/:addr"
+	Api_GetTxOut            = "/api/v1/txout/:hash/:vout"
 	Api_SendRawTransaction  = "/api/v1/transaction"
+	Api_TestTxPoolAccept    = "/api/v1/transaction/testaccept"
 	Api_GetTransactionPool  = "/api/v1/transactionpool"
+	Api_GetTxPoolInfo       = "/api/v1/transactionpool/info"
+	Api_EstimateFee         = "/api/v1/fee/estimate/:blocks"
 	Api_Restart             = "/api/v1/restart"
 )
 
@@ -106,17 +111,22 @@ func (rt *restServer) initializeMethod() {
 		Api_Getblockheight:      {name: "getblockheight", handler: servers.GetBlockHeight},
 		Api_Getblockhash:        {name: "getblockhash", handler: servers.GetBlockHash},
 		Api_GetTransactionPool:  {name: "gettransactionpool", handler: servers.GetTransactionPool},
+		Api_GetTxPoolInfo:       {name: "getmempoolinfo", handler: servers.GetTxPoolInfo},
+		Api_EstimateFee:         {name: "estimatefee", handler: servers.EstimateFee},
 		Api_Gettransaction:      {name: "gettransaction", handler: servers.GetTransactionByHash},
 		Api_Getasset:            {name: "getasset", handler: servers.GetAssetByHash},
 		Api_GetUTXObyAddr:       {name: "getutxobyaddr", handler: servers.GetUnspends},
 		Api_GetUTXObyAsset:      {name: "getutxobyasset", handler: servers.GetUnspendOutput},
 		Api_GetBalanceByAddr:    {name: "getbalancebyaddr", handler: servers.GetBalanceByAddr},
 		Api_GetBalancebyAsset:   {name: "getbalancebyasset", handler: servers.GetBalanceByAsset},
+		Api_GetTxsByAddr:        {name: "gettransactionsbyaddress", handler: servers.GetTransactionsByAddress},
+		Api_GetTxOut:            {name: "gettxout", handler: servers.GetTxOut},
 		Api_Restart:             {name: "restart", handler: rt.Restart},
 	}
 
 	postMethodMap := map[string]Action{
 		Api_SendRawTransaction: {name: "sendrawtransaction", handler: servers.SendRawTransaction},
+		Api_TestTxPoolAccept:   {name: "testmempoolaccept", handler: servers.TestTxPoolAccept},
 	}
 	rt.postMap = postMethodMap
 	rt.getMap = getMethodMap
@@ -124,7 +134,10 @@ func (rt *restServer) initializeMethod() {
 
 func (rt *restServer) getPath(url string) string {
 
-	if strings.Contains(url, strings.TrimRight(Api_GetblockTxsByHeight, ":height")) {
+	// the path is under the path of gettransaction
+	if strings.Contains(url, Api_TestTxPoolAccept) {
+		return Api_TestTxPoolAccept
+	} else if strings.Contains(url, strings.TrimRight(Api_GetblockTxsByHeight, ":height")) {
 		return Api_GetblockTxsByHeight
 	} else if strings.Contains(url, strings.TrimRight(Api_Getblockbyheight, ":height")) {
 		return Api_Getblockbyheight
@@ -144,6 +157,12 @@ func (rt *restServer) getPath(url string) string {
 		return Api_GetUTXObyAsset
 	} else if strings.Contains(url, strings.TrimRight(Api_Getasset, ":hash")) {
 		return Api_Getasset
+	} else if strings.Contains(url, strings.TrimRight(Api_GetTxsByAddr, ":addr")) {
+		return Api_GetTxsByAddr
+	} else if strings.Contains(url, strings.TrimRight(Api_GetTxOut, ":hash/:vout")) {
+		return Api_GetTxOut
+	} else if strings.Contains(url, strings.TrimRight(Api_EstimateFee, ":blocks")) {
+		return Api_EstimateFee
 	}
 	return url
 }
@@ -167,6 +186,11 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 
 	case Api_GetTransactionPool:
 
+	case Api_GetTxPoolInfo:
+
+	case Api_EstimateFee:
+		req["blocks"] = getParam(r, "blocks")
+
 	case Api_Getblockhash:
 		req["height"] = getParam(r, "height")
 
@@ -190,10 +214,21 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["addr"] = getParam(r, "addr")
 		req["assetid"] = getParam(r, "assetid")
 
+	case Api_GetTxsByAddr:
+		req["address"] = getParam(r, "addr")
+		req["skip"] = r.URL.Query().Get("skip")
+		req["limit"] = r.URL.Query().Get("limit")
+
+	case Api_GetTxOut:
+		req["txid"] = getParam(r, "hash")
+		req["vout"] = getParam(r, "vout")
+
 	case Api_Restart:
 
 	case Api_SendRawTransaction:
 
+	case Api_TestTxPoolAccept:
+
 	}
 	return req
 }
@@ -29,6 +29,8 @@ var (
 	PushRawBlockFlag = true
 	PushBlockTxsFlag = true
 	PushNewTxsFlag   = true
+	PushReplacedFlag = true
+	PushExpiredFlag  = true
 )
 
 type Handler func(Params) map[string]interface{}
@@ -46,6 +48,8 @@ type WebSocketServer struct {
 func StartServer() {
 	chain.DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventBlockPersistCompleted, SendBlock2WSclient)
 	chain.DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventNewTransactionPutInPool, SendTransaction2WSclient)
+	chain.DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventTransactionReplaced, SendReplacement2WSclient)
+	chain.DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventTransactionExpired, SendExpiration2WSclient)
 
 	instance = &WebSocketServer{
 		Upgrader:    websocket.Upgrader{},
@@ -242,6 +246,22 @@ func SendTransaction2WSclient(v interface{}) {
 	}
 }
 
+func SendReplacement2WSclient(v interface{}) {
+	if PushReplacedFlag {
+		go func() {
+			instance.PushResult("sendreplacedtransaction", v)
+		}()
+	}
+}
+
+func SendExpiration2WSclient(v interface{}) {
+	if PushExpiredFlag {
+		go func() {
+			instance.PushResult("sendexpiredtransaction", v)
+		}()
+	}
+}
+
 func SendBlock2WSclient(v interface{}) {
 	//if PushBlockFlag {
 	//	go func() {
@@ -277,10 +297,21 @@ func (server *WebSocketServer) PushResult(action string, v interface{}) {
 		if block, ok := v.(*Block); ok {
 			result = GetBlockTransactions(block)
 		}
-	case "sendnewtransaction":
+	case "sendnewtransaction", "sendexpiredtransaction":
 		if tx, ok := v.(*Transaction); ok {
 			result = GetTransactionInfo(nil, tx)
 		}
+	case "sendreplacedtransaction":
+		if replacement, ok := v.(*chain.TxReplacement); ok {
+			replaced := make([]string, 0, len(replacement.Replaced))
+			for _, tx := range replacement.Replaced {
+				replaced = append(replaced, ToReversedString(tx.Hash()))
+			}
+			result = TxReplacementInfo{
+				Txid:     ToReversedString(replacement.Transaction.Hash()),
+				Replaced: replaced,
+			}
+		}
 	default:
 		log.Error("httpwebsocket/server.go in pushresult function: unknown action")
 	}
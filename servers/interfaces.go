@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	aux "github.com/elastos/Elastos.ELA/auxpow"
@@ -17,11 +18,13 @@ import (
 	. "github.com/elastos/Elastos.ELA/protocol"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
 	"github.com/elastos/Elastos.ELA.Utility/p2p"
 )
 
 const (
 	AUXBLOCK_GENERATED_INTERVAL_SECONDS = 60
+	MaxAddressTxsPerRequest             = 1000
 )
 
 var ServerNode Noder
@@ -66,6 +69,9 @@ func GetTransactionInfo(header *Header, tx *Transaction) *TransactionInfo {
 	for i, v := range tx.Programs {
 		programs[i].Code = BytesToHexString(v.Code)
 		programs[i].Parameter = BytesToHexString(v.Parameter)
+		if IsHTLCCode(v.Code) {
+			programs[i].HTLC = getHTLCInfo(v.Code, v.Parameter)
+		}
 	}
 
 	var txHash = tx.Hash()
@@ -322,19 +328,27 @@ func CreateAuxBlock(param Params) map[string]interface{} {
 
 func GetInfo(param Params) map[string]interface{} {
 	_, count := ServerNode.GetConnectionCount()
+	checkpoints := make([]CheckpointInfo, 0)
+	for _, checkpoint := range chain.DefaultLedger.Blockchain.Checkpoints() {
+		checkpoints = append(checkpoints, CheckpointInfo{
+			Height: checkpoint.Height,
+			Hash:   ToReversedString(checkpoint.Hash),
+		})
+	}
 	RetVal := struct {
-		Version        int    `json:"version"`
-		Balance        int    `json:"balance"`
-		Blocks         uint64 `json:"blocks"`
-		Timeoffset     int    `json:"timeoffset"`
-		Connections    uint   `json:"connections"`
-		Testnet        bool   `json:"testnet"`
-		Keypoololdest  int    `json:"keypoololdest"`
-		Keypoolsize    int    `json:"keypoolsize"`
-		Unlocked_until int    `json:"unlocked_until"`
-		Paytxfee       int    `json:"paytxfee"`
-		Relayfee       int    `json:"relayfee"`
-		Errors         string `json:"errors"`
+		Version        int              `json:"version"`
+		Balance        int              `json:"balance"`
+		Blocks         uint64           `json:"blocks"`
+		Timeoffset     int              `json:"timeoffset"`
+		Connections    uint             `json:"connections"`
+		Testnet        bool             `json:"testnet"`
+		Keypoololdest  int              `json:"keypoololdest"`
+		Keypoolsize    int              `json:"keypoolsize"`
+		Unlocked_until int              `json:"unlocked_until"`
+		Paytxfee       int              `json:"paytxfee"`
+		Relayfee       int              `json:"relayfee"`
+		Errors         string           `json:"errors"`
+		Checkpoints    []CheckpointInfo `json:"checkpoints"`
 	}{
 		Version:        config.Parameters.Version,
 		Balance:        0,
@@ -346,7 +360,8 @@ func GetInfo(param Params) map[string]interface{} {
 		Unlocked_until: 0,
 		Paytxfee:       0,
 		Relayfee:       0,
-		Errors:         "Tobe written"}
+		Errors:         "Tobe written",
+		Checkpoints:    checkpoints}
 	return ResponsePack(Success, &RetVal)
 }
 
@@ -411,6 +426,166 @@ func GetTransactionPool(param Params) map[string]interface{} {
 	return ResponsePack(Success, txs)
 }
 
+func GetTxPoolInfo(param Params) map[string]interface{} {
+	return ResponsePack(Success, TxPoolInfo{
+		Size:     ServerNode.GetTransactionCount(),
+		Bytes:    ServerNode.GetTxPoolSize(),
+		MaxBytes: config.Parameters.TxPoolSize(),
+		MinFee:   ServerNode.GetMinFeePerKB().String(),
+		Orphans:  ServerNode.GetOrphanCount(),
+	})
+}
+
+func EstimateFee(param Params) map[string]interface{} {
+	blocks, ok := param.Uint("blocks")
+	if !ok || blocks < 1 || blocks > chain.MaxFeeEstimateBlocks {
+		return ResponsePack(InvalidParams, fmt.Sprintf("blocks parameter should be an integer in 1-%d",
+			chain.MaxFeeEstimateBlocks))
+	}
+
+	fee, err := ServerNode.EstimateFee(blocks)
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, FeeEstimateInfo{
+		FeePerKB: fee.String(),
+		Blocks:   blocks,
+	})
+}
+
+func GetSigCacheInfo(param Params) map[string]interface{} {
+	stats := chain.DefaultSigCache.Stats()
+	var hitRate float64
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		hitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return ResponsePack(Success, SigCacheInfo{
+		Entries:    stats.Entries,
+		MaxEntries: stats.MaxEntries,
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		HitRate:    hitRate,
+	})
+}
+
+func GetDeploymentInfo(param Params) map[string]interface{} {
+	infos, err := chain.DefaultLedger.Blockchain.GetDeploymentInfo()
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	deployments := make([]DeploymentInfo, 0, len(infos))
+	for _, info := range infos {
+		deployments = append(deployments, DeploymentInfo{
+			Name:       info.Deployment.Name,
+			Bit:        info.Deployment.Bit,
+			StartTime:  info.Deployment.StartTime,
+			ExpireTime: info.Deployment.ExpireTime,
+			Threshold:  info.Deployment.Threshold,
+			Window:     info.Window,
+			State:      info.State.String(),
+			Count:      info.Count,
+			Elapsed:    info.Elapsed,
+		})
+	}
+	return ResponsePack(Success, deployments)
+}
+
+// getHTLCInfo returns the content of the HTLC program, and the preimage if it
+// is spent by the recipient, or nil if the program is not valid.
+func getHTLCInfo(code, parameter []byte) *HTLCInfo {
+	script, err := ParseHTLCScript(code)
+	if err != nil {
+		return nil
+	}
+	info, err := newHTLCInfo(script, code)
+	if err != nil {
+		return nil
+	}
+	if _, preimage, err := ParseHTLCParameter(parameter); err == nil {
+		info.Preimage = BytesToHexString(preimage)
+	}
+	return info
+}
+
+func newHTLCInfo(script *HTLCScript, code []byte) (*HTLCInfo, error) {
+	recipient, err := script.Recipient.EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := script.Sender.EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+	programHash, err := ToHTLCProgramHash(code)
+	if err != nil {
+		return nil, err
+	}
+	address, err := programHash.ToAddress()
+	if err != nil {
+		return nil, err
+	}
+	return &HTLCInfo{
+		Recipient:  BytesToHexString(recipient),
+		Sender:     BytesToHexString(sender),
+		Hash:       BytesToHexString(script.Hash[:]),
+		LockHeight: script.LockHeight,
+		Address:    address,
+	}, nil
+}
+
+func CreateHTLC(param Params) map[string]interface{} {
+	recipient, ok := param.String("recipient")
+	if !ok {
+		return ResponsePack(InvalidParams, "recipient public key not found")
+	}
+	sender, ok := param.String("sender")
+	if !ok {
+		return ResponsePack(InvalidParams, "sender public key not found")
+	}
+	hash, ok := param.String("hash")
+	if !ok {
+		return ResponsePack(InvalidParams, "hash not found")
+	}
+	lockHeight, ok := param.Uint("lockheight")
+	if !ok {
+		return ResponsePack(InvalidParams, "lockheight not found")
+	}
+
+	var script HTLCScript
+	var err error
+	if script.Recipient, err = decodePublicKey(recipient); err != nil {
+		return ResponsePack(InvalidParams, "invalid recipient public key, "+err.Error())
+	}
+	if script.Sender, err = decodePublicKey(sender); err != nil {
+		return ResponsePack(InvalidParams, "invalid sender public key, "+err.Error())
+	}
+	hashBytes, err := HexStringToBytes(hash)
+	if err != nil || len(hashBytes) != len(script.Hash) {
+		return ResponsePack(InvalidParams, "hash should be a 32 bytes hex string")
+	}
+	copy(script.Hash[:], hashBytes)
+	script.LockHeight = lockHeight
+
+	code, err := CreateHTLCRedeemScript(&script)
+	if err != nil {
+		return ResponsePack(InvalidParams, err.Error())
+	}
+	info, err := newHTLCInfo(&script, code)
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	info.Code = BytesToHexString(code)
+	return ResponsePack(Success, info)
+}
+
+func decodePublicKey(str string) (*crypto.PublicKey, error) {
+	bytes, err := HexStringToBytes(str)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecodePoint(bytes)
+}
+
 func GetBlockInfo(block *Block, verbose bool) BlockInfo {
 	var txs []interface{}
 	if verbose {
@@ -519,6 +694,38 @@ func SendRawTransaction(param Params) map[string]interface{} {
 	return ResponsePack(Success, ToReversedString(txn.Hash()))
 }
 
+func TestTxPoolAccept(param Params) map[string]interface{} {
+	str, ok := param.String("data")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a string parameter named data")
+	}
+
+	bys, err := HexStringToBytes(str)
+	if err != nil {
+		return ResponsePack(InvalidParams, "hex string to bytes error")
+	}
+	var txn Transaction
+	if err := txn.Deserialize(bytes.NewReader(bys)); err != nil {
+		return ResponsePack(InvalidTransaction, "transaction deserialize error")
+	}
+
+	fee, ruleErr := ServerNode.TestAcceptTransaction(&txn)
+	size := txn.GetSize()
+	info := TxAcceptInfo{
+		Txid:     ToReversedString(txn.Hash()),
+		Allowed:  ruleErr == nil,
+		Size:     size,
+		Fee:      fee.String(),
+		FeePerKB: (fee * 1000 / Fixed64(size)).String(),
+	}
+	if ruleErr != nil {
+		info.RejectCode = int(ruleErr.Code)
+		info.RejectRule = ruleErr.Rule
+		info.RejectReason = ruleErr.Err.Error()
+	}
+	return ResponsePack(Success, info)
+}
+
 func GetBlockHeight(param Params) map[string]interface{} {
 	return ResponsePack(Success, chain.DefaultLedger.Blockchain.BlockHeight)
 }
@@ -738,6 +945,99 @@ func GetReceivedByAddress(param Params) map[string]interface{} {
 	return ResponsePack(Success, totalValue.String())
 }
 
+func GetTransactionsByAddress(param Params) map[string]interface{} {
+	address, ok := param.String("address")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named address")
+	}
+	programHash, err := Uint168FromAddress(address)
+	if err != nil {
+		return ResponsePack(InvalidParams, "Invalid address: "+address)
+	}
+	skip, ok := param.Uint("skip")
+	if !ok {
+		skip = 0
+	}
+	limit, ok := param.Uint("limit")
+	if !ok || limit == 0 || limit > MaxAddressTxsPerRequest {
+		limit = MaxAddressTxsPerRequest
+	}
+
+	txs, err := chain.DefaultLedger.Store.GetAddressTransactions(*programHash, skip, limit)
+	if err != nil {
+		return ResponsePack(InternalError, "get address transactions failed, "+err.Error())
+	}
+
+	bestHeight := chain.DefaultLedger.Blockchain.GetBestHeight()
+	result := AddressTxsInfo{
+		Transactions: make([]AddressTxInfo, 0, len(txs)),
+	}
+	for _, tx := range txs {
+		result.Transactions = append(result.Transactions, AddressTxInfo{
+			Txid:          ToReversedString(tx.TxId),
+			Height:        tx.Height,
+			Confirmations: bestHeight - tx.Height + 1,
+		})
+	}
+	return ResponsePack(Success, result)
+}
+
+func GetTxOut(param Params) map[string]interface{} {
+	str, ok := param.String("txid")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a parameter named txid")
+	}
+	vout, ok := param.Uint("vout")
+	if !ok || vout > math.MaxUint16 {
+		return ResponsePack(InvalidParams, "need a parameter named vout")
+	}
+	bys, err := FromReversedString(str)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	var txid Uint256
+	if err := txid.Deserialize(bytes.NewReader(bys)); err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	txn, height, err := chain.DefaultLedger.Store.GetTransaction(txid)
+	if err != nil {
+		return ResponsePack(UnknownTransaction, "")
+	}
+	if int(vout) >= len(txn.Outputs) {
+		return ResponsePack(InvalidParams, "vout out of range")
+	}
+	output := txn.Outputs[vout]
+	address, err := output.ProgramHash.ToAddress()
+	if err != nil {
+		return ResponsePack(InternalError, "invalid output program hash")
+	}
+
+	bestHeight := chain.DefaultLedger.Blockchain.GetBestHeight()
+	result := TxOutInfo{
+		Txid:          ToReversedString(txid),
+		VOut:          uint16(vout),
+		AssetId:       ToReversedString(output.AssetID),
+		Address:       address,
+		Amount:        output.Value.String(),
+		OutputLock:    output.OutputLock,
+		Height:        height,
+		Confirmations: bestHeight - height + 1,
+	}
+	if unspent, _ := chain.DefaultLedger.Store.ContainsUnspent(txid, uint16(vout)); !unspent {
+		result.Spent = true
+		// the spender is unknown if the store is restored from a UTXO snapshot
+		if spentBy, err := chain.DefaultLedger.Store.GetSpentBy(txid, uint16(vout)); err == nil {
+			result.SpentBy = &SpenderInfo{
+				Txid:          ToReversedString(spentBy.TxId),
+				Height:        spentBy.Height,
+				Confirmations: bestHeight - spentBy.Height + 1,
+			}
+		}
+	}
+	return ResponsePack(Success, result)
+}
+
 func ListUnspent(param Params) map[string]interface{} {
 	bestHeight := chain.DefaultLedger.Blockchain.GetBestHeight()
 
@@ -968,14 +1268,18 @@ func getPayloadInfo(p Payload) PayloadInfo {
 
 func VerifyAndSendTx(txn *Transaction) ErrCode {
 	// if transaction is verified unsucessfully then will not put it into transaction pool
-	if errCode := ServerNode.AppendToTxnPool(txn); errCode != Success {
+	accepted, errCode := ServerNode.ProcessTransaction(txn, false, 0)
+	if errCode != Success {
 		log.Warn("Can NOT add the transaction to TxnPool")
 		log.Info("[httpjsonrpc] VerifyTransaction failed when AppendToTxnPool. Errcode:", errCode)
 		return errCode
 	}
-	if err := ServerNode.Relay(nil, txn); err != nil {
-		log.Error("Xmit Tx Error:Relay transaction failed.", err)
-		return ErrXmitFail
+	// the orphan transactions spending its outputs are accepted too
+	for _, txn := range accepted {
+		if err := ServerNode.Relay(nil, txn); err != nil {
+			log.Error("Xmit Tx Error:Relay transaction failed.", err)
+			return ErrXmitFail
+		}
 	}
 	return Success
 }